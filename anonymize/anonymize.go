@@ -0,0 +1,628 @@
+// Package anonymize replaces IP addresses, hostnames, and BGP AS
+// numbers in VyOS configs with consistent pseudonyms, so that configs
+// can be shared for troubleshooting without giving away the details
+// of a network.
+//
+// The mapping is keyed: the same key always produces the same
+// pseudonyms, so several routers' configs anonymized with one key
+// still agree with each other.  IP addresses are mapped with a
+// prefix-preserving scheme (like Crypto-PAn), so two addresses that
+// share an N-bit prefix still share an N-bit prefix afterward, and
+// prefix lengths are unchanged.  Every replacement is recorded in a
+// Mapping, which can be saved locally and used to reverse the
+// process.
+//
+// AS numbers are the exception to the key being enough: they're
+// mapped into the small private ASN ranges, so collisions are
+// resolved in the order that AS numbers are first seen.  Within one
+// call to Anonymize they're assigned in numeric order, but configs
+// anonymized separately only get reproducible AS numbers if they
+// share a saved Mapping.
+package anonymize
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/scottlaird/vyos-parser/parser"
+)
+
+// DefaultHostnameQueries lists the config values that hold hostnames
+// or domain names.
+var DefaultHostnameQueries = []string{
+	"system/host-name",
+	"system/domain-name",
+	"system/domain-search",
+	"system/static-host-mapping/host-name",
+	"system/static-host-mapping/host-name/*/alias",
+	"system/syslog/host",
+	"system/syslog/remote",
+	"service/ntp/server",
+	"service/dns/forwarding/domain",
+	"service/dhcp-server/**/domain-name",
+	"service/dhcp-server/**/domain-search",
+}
+
+// DefaultASNQueries lists the config values that hold BGP AS numbers,
+// in the global BGP config and in VRFs.
+var DefaultASNQueries = []string{
+	"**/protocols/bgp/system-as",
+	"**/protocols/bgp/**/remote-as",
+	"**/protocols/bgp/**/local-as",
+	"**/protocols/bgp/parameters/confederation/identifier",
+	"**/protocols/bgp/parameters/confederation/peers",
+}
+
+// DefaultASPathQueries lists the config values that hold AS paths,
+// like `65001 65001`, or AS path regular expressions, like `^65001_`.
+var DefaultASPathQueries = []string{
+	"policy/as-path-list/*/rule/*/regex",
+	"policy/route-map/*/rule/*/set/as-path/prepend",
+	"policy/route-map/*/rule/*/set/as-path/exclude",
+}
+
+// DefaultCommunityQueries lists the config values that hold BGP
+// communities (`65001:100`), large communities (`65001:1:2`), or
+// extended communities (`65001:100` or `192.0.2.1:100`), or regular
+// expressions that match them.
+var DefaultCommunityQueries = []string{
+	"policy/community-list/*/rule/*/regex",
+	"policy/large-community-list/*/rule/*/regex",
+	"policy/extcommunity-list/*/rule/*/regex",
+	"policy/route-map/*/rule/*/set/community/add",
+	"policy/route-map/*/rule/*/set/community/replace",
+	"policy/route-map/*/rule/*/set/large-community/add",
+	"policy/route-map/*/rule/*/set/large-community/replace",
+	"policy/route-map/*/rule/*/set/extcommunity/rt",
+	"policy/route-map/*/rule/*/set/extcommunity/soo",
+}
+
+// Options controls what New produces.
+type Options struct {
+	// Mapping is an existing mapping to add to, usually from a
+	// previous run.  Values already in it keep their pseudonyms.
+	// Reusing a Mapping is the only way to get the same AS
+	// numbers for configs that are anonymized separately.
+	Mapping *Mapping
+
+	// HostnameQueries, ASNQueries, ASPathQueries, and
+	// CommunityQueries list extra config values (as parser.Query
+	// strings) that hold each kind of value, in addition to the
+	// defaults.
+	HostnameQueries  []string
+	ASNQueries       []string
+	ASPathQueries    []string
+	CommunityQueries []string
+}
+
+// kind is the kind of value that a query finds.
+type kind int
+
+const (
+	otherValue kind = iota
+	asnValue
+	asPathValue
+	communityValue
+	hostnameValue
+)
+
+// target is the compiled queries for one kind of value.
+type target struct {
+	kind    kind
+	queries []*parser.Query
+}
+
+// targets lists the queries for every kind of value, in priority
+// order.
+type targets []target
+
+// newTargets compiles the default queries plus the extra ones in
+// `options`.
+func newTargets(options *Options) (targets, error) {
+	t := targets{}
+	for _, k := range []struct {
+		kind    kind
+		queries []string
+	}{
+		{asnValue, slices.Concat(DefaultASNQueries, options.ASNQueries)},
+		{asPathValue, slices.Concat(DefaultASPathQueries, options.ASPathQueries)},
+		{communityValue, slices.Concat(DefaultCommunityQueries, options.CommunityQueries)},
+		{hostnameValue, slices.Concat(DefaultHostnameQueries, options.HostnameQueries)},
+	} {
+		compiled := []*parser.Query{}
+		for _, query := range k.queries {
+			q, err := parser.CompileQuery(query)
+			if err != nil {
+				return nil, err
+			}
+			compiled = append(compiled, q)
+		}
+		t = append(t, target{k.kind, compiled})
+	}
+	return t, nil
+}
+
+// match returns the kind of every node in `ast` that a query finds.
+// Nodes found by more than one kind of query get the first.
+func (t targets) match(ast *parser.VyOSConfigAST) map[*parser.Node]kind {
+	kinds := map[*parser.Node]kind{}
+	for _, target := range t {
+		for _, q := range target.queries {
+			for _, result := range q.Match(ast) {
+				if _, ok := kinds[result.Node]; !ok {
+					kinds[result.Node] = target.kind
+				}
+			}
+		}
+	}
+	return kinds
+}
+
+// Mapping records every value that an Anonymizer replaced, keyed by
+// the original value.
+type Mapping struct {
+	Addresses map[string]string `json:"addresses"`
+	Hostnames map[string]string `json:"hostnames"`
+	ASNs      map[string]string `json:"asns"`
+}
+
+// NewMapping returns an empty Mapping.
+func NewMapping() *Mapping {
+	return &Mapping{
+		Addresses: map[string]string{},
+		Hostnames: map[string]string{},
+		ASNs:      map[string]string{},
+	}
+}
+
+// ReadMapping reads a Mapping written by Mapping.Write.
+func ReadMapping(r io.Reader) (*Mapping, error) {
+	m := NewMapping()
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("Failed to read mapping: %v", err)
+	}
+	// Files with empty sections decode as nil maps.
+	for _, section := range []*map[string]string{&m.Addresses, &m.Hostnames, &m.ASNs} {
+		if *section == nil {
+			*section = map[string]string{}
+		}
+	}
+	return m, nil
+}
+
+// Write writes the mapping as JSON.  Anyone with the mapping can
+// undo the anonymization, so it should be kept private.
+func (m *Mapping) Write(w io.Writer) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Restore returns a copy of an anonymized AST with the original
+// values put back.  Addresses are restored anywhere, but hostnames
+// and AS numbers only where the queries say they belong, so that
+// other values that happen to equal a pseudonym, like port numbers,
+// are left alone.  `options` should have the same queries that were
+// used to anonymize the config, and may be nil; its Mapping isn't
+// used.  It returns an error if one of the queries is invalid.
+func (m *Mapping) Restore(ast *parser.VyOSConfigAST, options *Options) (*parser.VyOSConfigAST, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	t, err := newTargets(options)
+	if err != nil {
+		return nil, err
+	}
+	addresses, hostnames, asns := reverse(m.Addresses), reverse(m.Hostnames), reverse(m.ASNs)
+
+	c := ast.Copy()
+	kinds := t.match(c)
+	walk(c.Child, func(n *parser.Node) {
+		value := *n.Value
+		if real, ok := addresses[value]; ok {
+			n.Value = &real
+			return
+		}
+		real := rewrite(kinds[n], value, asns.get, addresses.get)
+		if kinds[n] == hostnameValue {
+			real = hostnames.get(value)
+		}
+		n.Value = &real
+	})
+	return c, nil
+}
+
+// reverseMap maps pseudonyms back to the original values.
+type reverseMap map[string]string
+
+// reverse returns the reverse of one section of a Mapping.
+func reverse(section map[string]string) reverseMap {
+	r := reverseMap{}
+	for real, fake := range section {
+		r[fake] = real
+	}
+	return r
+}
+
+// get returns the original value for `fake`, or `fake` itself if it
+// isn't a pseudonym.
+func (r reverseMap) get(fake string) string {
+	if real, ok := r[fake]; ok {
+		return real
+	}
+	return fake
+}
+
+// Anonymizer replaces addresses, hostnames, and AS numbers in config
+// ASTs.  Use the same Anonymizer (or the same key and Mapping) for
+// every config that needs to be consistent.
+type Anonymizer struct {
+	key      []byte
+	mapping  *Mapping
+	targets  targets
+	usedASNs map[uint64]bool
+}
+
+// New returns an Anonymizer that uses `key` to pick pseudonyms.  It
+// returns an error if `key` is empty or one of the queries in
+// `options` is invalid.  `options` may be nil.
+func New(key []byte, options *Options) (*Anonymizer, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("No key specified")
+	}
+	if options == nil {
+		options = &Options{}
+	}
+
+	a := &Anonymizer{
+		key:      slices.Clone(key),
+		mapping:  options.Mapping,
+		usedASNs: map[uint64]bool{},
+	}
+	if a.mapping == nil {
+		a.mapping = NewMapping()
+	}
+	for _, fake := range a.mapping.ASNs {
+		if n, err := strconv.ParseUint(fake, 10, 32); err == nil {
+			a.usedASNs[n] = true
+		}
+	}
+
+	var err error
+	a.targets, err = newTargets(options)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Mapping returns every replacement made so far.
+func (a *Anonymizer) Mapping() *Mapping {
+	return a.mapping
+}
+
+// Anonymize returns a copy of `ast` with IP addresses, hostnames, and
+// AS numbers replaced.  Addresses are found anywhere in the config,
+// including in prefixes (`192.0.2.0/24`), ranges, and negated
+// firewall matches (`!192.0.2.1`).  Loopback, link-local, multicast,
+// and unspecified addresses are left alone, since they're the same
+// on every network.  AS numbers inside of AS paths and communities
+// get the same pseudonyms as everywhere else.
+func (a *Anonymizer) Anonymize(ast *parser.VyOSConfigAST) *parser.VyOSConfigAST {
+	c := ast.Copy()
+	kinds := a.targets.match(c)
+
+	// Assign AS numbers in numeric order rather than config order,
+	// so that collisions are resolved the same way no matter where
+	// each one appears.
+	asns := []string{}
+	for n, k := range kinds {
+		rewrite(k, *n.Value, func(value string) string {
+			asns = append(asns, value)
+			return value
+		}, keep)
+	}
+	for _, value := range sortedASNs(asns) {
+		a.asn(value)
+	}
+
+	walk(c.Child, func(n *parser.Node) {
+		value := *n.Value
+		if fake, ok := a.address(value); ok {
+			n.Value = &fake
+			return
+		}
+		fake := rewrite(kinds[n], value, a.mapASN, a.mapAddress)
+		if kinds[n] == hostnameValue {
+			fake = a.hostname(value)
+		}
+		n.Value = &fake
+	})
+	return c
+}
+
+// rewrite returns `value`, which is a value of kind `k`, with each AS
+// number in it replaced by `asn` and each address in a community
+// replaced by `addr`.  Other kinds of values are returned unchanged.
+func rewrite(k kind, value string, asn, addr func(string) string) string {
+	switch k {
+	case asnValue:
+		return asn(value)
+	case asPathValue:
+		return mapASPath(value, asn)
+	case communityValue:
+		return mapCommunities(value, asn, addr)
+	}
+	return value
+}
+
+// mapASPath calls `asn` for every AS number in an AS path or AS path
+// regular expression.  Digits inside of `[...]` and `{...}` are part
+// of the regular expression, not AS numbers.
+func mapASPath(value string, asn func(string) string) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(value); {
+		c := value[i]
+		switch {
+		case c == '[' || c == '{':
+			depth++
+		case (c == ']' || c == '}') && depth > 0:
+			depth--
+		case isDigit(c) && depth == 0:
+			j := i
+			for j < len(value) && isDigit(value[j]) {
+				j++
+			}
+			b.WriteString(asn(value[i:j]))
+			i = j
+			continue
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String()
+}
+
+// mapCommunities calls `asn` or `addr` for the first field of every
+// community in `value`, which is the AS number or address of the
+// network that defined it.  The other fields are that network's own
+// values, and are left alone.
+func mapCommunities(value string, asn, addr func(string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		j := i
+		for j < len(value) && (isDigit(value[j]) || value[j] == '.' || value[j] == ':') {
+			j++
+		}
+		if j == i {
+			b.WriteByte(value[i])
+			i++
+			continue
+		}
+		token := value[i:j]
+		if first, rest, found := strings.Cut(token, ":"); found {
+			if _, err := netip.ParseAddr(first); err == nil {
+				token = addr(first) + ":" + rest
+			} else if first != "" && !strings.Contains(first, ".") {
+				token = asn(first) + ":" + rest
+			}
+		}
+		b.WriteString(token)
+		i = j
+	}
+	return b.String()
+}
+
+// isDigit returns true if `c` is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// keep returns `value` unchanged.
+func keep(value string) string {
+	return value
+}
+
+// sortedASNs returns the values that are AS numbers, in numeric
+// order.
+func sortedASNs(values []string) []string {
+	asns := []string{}
+	for _, value := range values {
+		if _, err := strconv.ParseUint(value, 10, 32); err == nil {
+			asns = append(asns, value)
+		}
+	}
+	slices.SortFunc(asns, func(x, y string) int {
+		xn, _ := strconv.ParseUint(x, 10, 32)
+		yn, _ := strconv.ParseUint(y, 10, 32)
+		return cmp.Or(cmp.Compare(xn, yn), strings.Compare(x, y))
+	})
+	return asns
+}
+
+// walk calls `f` for `n` and every node underneath it that has a
+// value.
+func walk(n *parser.Node, f func(n *parser.Node)) {
+	if n.Value != nil {
+		f(n)
+	}
+	for _, child := range n.Children {
+		walk(child, f)
+	}
+}
+
+// mapAddress returns the pseudonym for an address, or the address
+// itself if it isn't mapped.
+func (a *Anonymizer) mapAddress(value string) string {
+	if fake, ok := a.address(value); ok {
+		return fake
+	}
+	return value
+}
+
+// mapASN returns the pseudonym for an AS number, or the value itself
+// if it isn't one, like `external`.
+func (a *Anonymizer) mapASN(value string) string {
+	if fake, ok := a.asn(value); ok {
+		return fake
+	}
+	return value
+}
+
+// address returns the pseudonym for a value containing an IP
+// address, prefix, or range, and false if it isn't one.
+func (a *Anonymizer) address(value string) (string, bool) {
+	if fake, ok := a.mapping.Addresses[value]; ok {
+		return fake, true
+	}
+
+	fake, ok := "", false
+	negated := strings.HasPrefix(value, "!")
+	v := strings.TrimPrefix(value, "!")
+	if addr, err := netip.ParseAddr(v); err == nil {
+		fake, ok = a.mapAddr(addr).String(), true
+	} else if prefix, err := netip.ParsePrefix(v); err == nil {
+		fake, ok = a.mapPrefix(prefix).String(), true
+	} else if start, end, found := strings.Cut(v, "-"); found {
+		startAddr, err1 := netip.ParseAddr(start)
+		endAddr, err2 := netip.ParseAddr(end)
+		if err1 == nil && err2 == nil {
+			// The mapping doesn't preserve order, so the
+			// range may need to be flipped around.
+			startAddr, endAddr = a.mapAddr(startAddr), a.mapAddr(endAddr)
+			if endAddr.Less(startAddr) {
+				startAddr, endAddr = endAddr, startAddr
+			}
+			fake, ok = startAddr.String()+"-"+endAddr.String(), true
+		}
+	}
+	if !ok || fake == v {
+		return "", false
+	}
+	if negated {
+		fake = "!" + fake
+	}
+	a.mapping.Addresses[value] = fake
+	return fake, true
+}
+
+// mapPrefix maps the address in `prefix`.  Network prefixes (with no
+// host bits set) stay network prefixes.
+func (a *Anonymizer) mapPrefix(prefix netip.Prefix) netip.Prefix {
+	mapped := netip.PrefixFrom(a.mapAddr(prefix.Addr()), prefix.Bits())
+	if prefix.Addr() == prefix.Masked().Addr() {
+		mapped = mapped.Masked()
+	}
+	return mapped
+}
+
+// mapAddr implements the prefix-preserving mapping.  Each bit of the
+// address is flipped or not based on a keyed hash of the bits before
+// it, so addresses that share a prefix get the same flips for that
+// prefix.
+func (a *Anonymizer) mapAddr(addr netip.Addr) netip.Addr {
+	if addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsMulticast() || addr.IsUnspecified() || addr.Zone() != "" {
+		return addr
+	}
+
+	in := addr.AsSlice()
+	out := make([]byte, len(in))
+	prefix := make([]byte, len(in))
+	for i := 0; i < len(in)*8; i++ {
+		mac := hmac.New(sha256.New, a.key)
+		mac.Write([]byte{'a', byte(len(in)), byte(i)})
+		mac.Write(prefix)
+		flip := mac.Sum(nil)[0] & 1
+
+		shift := 7 - i%8
+		bit := (in[i/8] >> shift) & 1
+		out[i/8] |= (bit ^ flip) << shift
+		prefix[i/8] |= bit << shift
+	}
+	mapped, _ := netip.AddrFromSlice(out)
+	return mapped
+}
+
+// hostname returns the pseudonym for a hostname or domain name.  Each
+// label is replaced separately, so `router1.example.com` and
+// `example.com` still share a domain afterward.  The top-level domain
+// is kept, so names still look like names.
+func (a *Anonymizer) hostname(value string) string {
+	if fake, ok := a.mapping.Hostnames[value]; ok {
+		return fake
+	}
+
+	name, dot := strings.CutSuffix(value, ".")
+	labels := strings.Split(name, ".")
+	keep := 0
+	if len(labels) > 1 {
+		keep = 1
+	}
+	for i := range labels[:len(labels)-keep] {
+		labels[i] = "h" + hex.EncodeToString(a.hash("h", strings.ToLower(labels[i]))[:4])
+	}
+	fake := strings.Join(labels, ".")
+	if dot {
+		fake = fake + "."
+	}
+	a.mapping.Hostnames[value] = fake
+	return fake
+}
+
+// asn returns the pseudonym for a BGP AS number, and false if `value`
+// isn't one.  16-bit AS numbers are mapped into the 16-bit private
+// range (64512-65534), and 32-bit AS numbers into the 32-bit private
+// range (4200000000-4294967294), so the results never collide with
+// real networks.  Since pseudonyms can't collide with each other
+// either, the result depends on which AS numbers were mapped before.
+func (a *Anonymizer) asn(value string) (string, bool) {
+	if fake, ok := a.mapping.ASNs[value]; ok {
+		return fake, true
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return "", false
+	}
+
+	base, size := uint64(64512), uint64(65535-64512)
+	if n > 65535 {
+		base, size = 4200000000, 4294967295-4200000000
+	}
+	// Try again with a counter on collisions, which are likely in
+	// the small 16-bit range.
+	var fake uint64
+	for i := 0; ; i++ {
+		h := a.hash("n", value+"/"+strconv.Itoa(i))
+		fake = base + binary.BigEndian.Uint64(h)%size
+		if !a.usedASNs[fake] {
+			break
+		}
+	}
+	a.usedASNs[fake] = true
+	a.mapping.ASNs[value] = strconv.FormatUint(fake, 10)
+	return a.mapping.ASNs[value], true
+}
+
+// hash returns a keyed hash of `value`.  `kind` separates the hashes
+// used for different kinds of values.
+func (a *Anonymizer) hash(kind, value string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,367 @@
+package anonymize
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/scottlaird/vyos-parser/parser"
+	"github.com/scottlaird/vyos-parser/syntax"
+)
+
+func parseTestConfig(t *testing.T, config string) *parser.VyOSConfigAST {
+	t.Helper()
+	configModel, err := syntax.GetDefaultConfigModel()
+	if err != nil {
+		t.Fatalf("Failed to load config model: %v", err)
+	}
+	ast, err := parser.ParseSetFormat(config, configModel)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	return ast
+}
+
+func readTestConfig(t *testing.T) string {
+	t.Helper()
+	b, err := os.ReadFile("../parser/testdata/config.set.1")
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	return string(b)
+}
+
+func get(t *testing.T, ast *parser.VyOSConfigAST, path string) []string {
+	t.Helper()
+	values, err := ast.Get(path)
+	if err != nil {
+		t.Fatalf("Get(%q) failed: %v", path, err)
+	}
+	return values
+}
+
+func TestAnonymizeAddresses(t *testing.T) {
+	ast := parseTestConfig(t, readTestConfig(t))
+	a, err := New([]byte("test key"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	anon := a.Anonymize(ast)
+
+	// The interface and its neighbor's ARP entry are still on the
+	// same /24, and the address is different.
+	address := netip.MustParsePrefix(get(t, anon, "interfaces ethernet eth0 address")[0])
+	if address.String() == "10.250.1.254/24" {
+		t.Errorf("Address wasn't anonymized")
+	}
+	arp := get(t, anon, "protocols static arp interface eth0 address")
+	if len(arp) != 1 || !address.Contains(netip.MustParseAddr(arp[0])) {
+		t.Errorf("ARP entry %q isn't in %s", arp, address)
+	}
+
+	// The same address maps the same way everywhere.
+	nextHops := get(t, anon, "protocols static route")
+	if len(nextHops) != 2 {
+		t.Fatalf("Expected 2 routes, got %q", nextHops)
+	}
+	route := netip.MustParsePrefix(nextHops[0])
+	if route.Bits() != 8 || route != route.Masked() {
+		t.Errorf("Route %s isn't a /8 network", route)
+	}
+	firewall := get(t, anon, "firewall ipv4 forward filter rule 900 destination address")
+	if len(firewall) != 1 || firewall[0] != route.String() {
+		t.Errorf("Firewall rule has %q, want %s", firewall, route)
+	}
+
+	// Default routes are left alone.
+	allow := get(t, anon, "service ntp allow-client address")
+	if strings.Join(allow, " ") != "0.0.0.0/0 ::/0" {
+		t.Errorf("allow-client is %q", allow)
+	}
+
+	// Words that aren't addresses are left alone.
+	if dhcp := get(t, anon, "interfaces ethernet eth5 address"); dhcp[0] != "dhcp" {
+		t.Errorf("eth5 address is %q, want dhcp", dhcp)
+	}
+
+	// The original AST isn't changed.
+	if get(t, ast, "interfaces ethernet eth0 address")[0] != "10.250.1.254/24" {
+		t.Errorf("Original AST was changed")
+	}
+}
+
+func TestAnonymizeHostnames(t *testing.T) {
+	ast := parseTestConfig(t, readTestConfig(t))
+	a, err := New([]byte("test key"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	anon := a.Anonymize(ast)
+
+	hostName := get(t, anon, "system host-name")[0]
+	domainName := get(t, anon, "system domain-name")[0]
+	if hostName == "router-test" || domainName == "internal.sigkill.org" {
+		t.Errorf("Names weren't anonymized: %q, %q", hostName, domainName)
+	}
+	if !strings.HasSuffix(domainName, ".org") || strings.Count(domainName, ".") != 2 {
+		t.Errorf("Domain %q doesn't keep its shape", domainName)
+	}
+
+	servers := get(t, anon, "service ntp server")
+	if len(servers) != 5 {
+		t.Fatalf("Expected 5 NTP servers, got %q", servers)
+	}
+	// 0.pool.ntp.org and 1.pool.ntp.org still share pool.ntp.org.
+	if !strings.HasSuffix(servers[1], strings.TrimPrefix(servers[0], strings.SplitN(servers[0], ".", 2)[0])) {
+		t.Errorf("NTP servers %q and %q don't share a domain", servers[0], servers[1])
+	}
+	if _, err := netip.ParseAddr(servers[3]); err != nil || servers[3] == "10.1.0.238" {
+		t.Errorf("NTP server %q should be an anonymized address", servers[3])
+	}
+}
+
+func TestAnonymizeASNs(t *testing.T) {
+	ast := parseTestConfig(t, `set protocols bgp neighbor 192.0.2.1 remote-as '64999'
+set protocols bgp neighbor 192.0.2.2 remote-as 'external'
+set protocols bgp neighbor 192.0.2.3 remote-as '4200000001'
+set protocols bgp system-as '15169'
+`)
+	a, err := New([]byte("test key"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	anon := a.Anonymize(ast)
+
+	neighbors := get(t, anon, "protocols bgp neighbor")
+	if len(neighbors) != 3 {
+		t.Fatalf("Expected 3 neighbors, got %q", neighbors)
+	}
+	remoteAS := []string{}
+	for _, neighbor := range neighbors {
+		remoteAS = append(remoteAS, get(t, anon, "protocols bgp neighbor "+neighbor+" remote-as")...)
+	}
+	systemAS := get(t, anon, "protocols bgp system-as")[0]
+
+	check := func(value string, low, high int) {
+		n := 0
+		for _, c := range value {
+			if c < '0' || c > '9' {
+				t.Errorf("ASN %q isn't a number", value)
+				return
+			}
+			n = n*10 + int(c-'0')
+		}
+		if n < low || n > high {
+			t.Errorf("ASN %q isn't in %d-%d", value, low, high)
+		}
+	}
+	check(remoteAS[0], 64512, 65534)
+	check(remoteAS[2], 4200000000, 4294967294)
+	check(systemAS, 64512, 65534)
+	if remoteAS[1] != "external" {
+		t.Errorf("remote-as external became %q", remoteAS[1])
+	}
+	if systemAS == "15169" || remoteAS[0] == systemAS {
+		t.Errorf("ASNs weren't mapped consistently: %q, %q", systemAS, remoteAS)
+	}
+}
+
+func TestAnonymizeCommunities(t *testing.T) {
+	ast := parseTestConfig(t, `set policy as-path-list AL rule 10 regex '^65001_[0-9]{2}$'
+set policy community-list CL rule 10 regex '65001:100'
+set policy large-community-list LCL rule 10 regex '65001:1:2'
+set policy route-map RM rule 10 set as-path prepend '65001 65001'
+set policy route-map RM rule 10 set community add '65001:200'
+set policy route-map RM rule 10 set extcommunity rt '192.0.2.1:300'
+set protocols bgp neighbor 192.0.2.1 remote-as '65001'
+set protocols bgp parameters confederation identifier '65002'
+set protocols bgp system-as '65003'
+`)
+	a, err := New([]byte("test key"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	anon := a.Anonymize(ast)
+
+	// AS 65001 gets the same pseudonym everywhere, and the rest of
+	// each community is unchanged.
+	asn := get(t, anon, "protocols bgp neighbor "+get(t, anon, "protocols bgp neighbor")[0]+" remote-as")[0]
+	if asn == "65001" {
+		t.Fatalf("remote-as wasn't anonymized")
+	}
+	address := a.Mapping().Addresses["192.0.2.1"]
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"policy as-path-list AL rule 10 regex", "^" + asn + "_[0-9]{2}$"},
+		{"policy community-list CL rule 10 regex", asn + ":100"},
+		{"policy large-community-list LCL rule 10 regex", asn + ":1:2"},
+		{"policy route-map RM rule 10 set as-path prepend", asn + " " + asn},
+		{"policy route-map RM rule 10 set community add", asn + ":200"},
+		{"policy route-map RM rule 10 set extcommunity rt", address + ":300"},
+	}
+	for _, test := range tests {
+		if got := get(t, anon, test.path); len(got) != 1 || got[0] != test.want {
+			t.Errorf("%s is %q, want %q", test.path, got, test.want)
+		}
+	}
+	if got := get(t, anon, "protocols bgp parameters confederation identifier")[0]; got != a.Mapping().ASNs["65002"] {
+		t.Errorf("Confederation identifier is %q, want %q", got, a.Mapping().ASNs["65002"])
+	}
+
+	restored, err := a.Mapping().Restore(anon, nil)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	want, _ := parser.WriteSetFormat(ast)
+	got, _ := parser.WriteSetFormat(restored)
+	if got != want {
+		t.Errorf("Restored config is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnonymizeASNOrder(t *testing.T) {
+	// With this many AS numbers in the 16-bit private range, some
+	// of them are sure to collide.
+	lines := []string{}
+	for i := 1; i <= 200; i++ {
+		lines = append(lines, fmt.Sprintf("set protocols bgp neighbor 192.0.2.%d remote-as '%d'", i, 65000+i))
+	}
+	mapping := func(lines []string) map[string]string {
+		a, err := New([]byte("test key"), nil)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		a.Anonymize(parseTestConfig(t, strings.Join(lines, "\n")+"\n"))
+		return a.Mapping().ASNs
+	}
+
+	forward := mapping(lines)
+	slices.Reverse(lines)
+	backward := mapping(lines)
+	if !maps.Equal(forward, backward) {
+		t.Errorf("AS numbers depend on the order of the config")
+	}
+
+	used := map[string]bool{}
+	for _, fake := range forward {
+		if used[fake] {
+			t.Errorf("AS number %q was used twice", fake)
+		}
+		used[fake] = true
+	}
+}
+
+func TestAnonymizeConsistency(t *testing.T) {
+	config := readTestConfig(t)
+	write := func(key string) string {
+		a, err := New([]byte(key), nil)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		out, err := parser.WriteSetFormat(a.Anonymize(parseTestConfig(t, config)))
+		if err != nil {
+			t.Fatalf("Failed to write set format: %v", err)
+		}
+		return out
+	}
+
+	if write("key 1") != write("key 1") {
+		t.Errorf("The same key gave different results")
+	}
+	if write("key 1") == write("key 2") {
+		t.Errorf("Different keys gave the same results")
+	}
+
+	if _, err := New(nil, nil); err == nil {
+		t.Errorf("Expected an error for an empty key")
+	}
+	if _, err := New([]byte("key"), &Options{HostnameQueries: []string{"system/["}}); err == nil {
+		t.Errorf("Expected an error for an invalid query")
+	}
+}
+
+func TestMappingRestore(t *testing.T) {
+	config := readTestConfig(t)
+	ast := parseTestConfig(t, config)
+	a, err := New([]byte("test key"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	anon := a.Anonymize(ast)
+
+	var buf bytes.Buffer
+	if err := a.Mapping().Write(&buf); err != nil {
+		t.Fatalf("Failed to write mapping: %v", err)
+	}
+	m, err := ReadMapping(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read mapping: %v", err)
+	}
+	if m.Hostnames["router-test"] != get(t, anon, "system host-name")[0] {
+		t.Errorf("Mapping doesn't include the host name: %v", m.Hostnames)
+	}
+
+	restoredAST, err := m.Restore(anon, nil)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored, err := parser.WriteSetFormat(restoredAST)
+	if err != nil {
+		t.Fatalf("Failed to write set format: %v", err)
+	}
+	if restored != config {
+		t.Errorf("Restored config doesn't match the original:\n%s", restored)
+	}
+
+	// A new Anonymizer with the same mapping picks up where the
+	// last one left off.
+	a2, err := New([]byte("test key"), &Options{Mapping: m})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	anon2 := a2.Anonymize(ast)
+	out1, _ := parser.WriteSetFormat(anon)
+	out2, _ := parser.WriteSetFormat(anon2)
+	if out1 != out2 {
+		t.Errorf("Reusing the mapping gave different results")
+	}
+}
+
+func TestMappingRestoreOnlyQueries(t *testing.T) {
+	a, err := New([]byte("test key"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	anon := a.Anonymize(parseTestConfig(t, "set protocols bgp system-as '65001'\n"))
+	fake := a.Mapping().ASNs["65001"]
+
+	// A port that happens to equal the pseudonym isn't an AS
+	// number, so it isn't restored.
+	config, err := parser.WriteSetFormat(anon)
+	if err != nil {
+		t.Fatalf("Failed to write set format: %v", err)
+	}
+	port := "set service ssh port '" + fake + "'\n"
+	restored, err := a.Mapping().Restore(parseTestConfig(t, config+port), nil)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	got, err := parser.WriteSetFormat(restored)
+	if err != nil {
+		t.Fatalf("Failed to write set format: %v", err)
+	}
+	if want := "set protocols bgp system-as '65001'\n" + port; got != want {
+		t.Errorf("Restored config is %q, want %q", got, want)
+	}
+
+	if _, err := a.Mapping().Restore(anon, &Options{ASNQueries: []string{"protocols/["}}); err == nil {
+		t.Errorf("Expected an error for an invalid query")
+	}
+}
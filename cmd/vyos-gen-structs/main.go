@@ -0,0 +1,58 @@
+// vyos-gen-structs generates a Go package of typed structs from one
+// of the embedded VyOS syntax models, for use with parser.Unmarshal
+// and parser.Marshal.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scottlaird/vyos-parser/structgen"
+	"github.com/scottlaird/vyos-parser/syntax"
+)
+
+var (
+	modelVersion = flag.String("model", "", "Syntax model to use, like vyos-20250129.  Defaults to the default model")
+	subtree      = flag.String("subtree", "", "Only generate structs for this part of the config, like \"protocols bgp\"")
+	pkg          = flag.String("package", "vyosconfig", "Name of the generated Go package")
+	rootType     = flag.String("type", "Config", "Name of the top-level struct")
+	out          = flag.String("out", "", "Output file")
+)
+
+func main() {
+	flag.Parse()
+
+	if *modelVersion == "" {
+		version, err := syntax.GetDefaultVersion()
+		if err != nil {
+			panic(err)
+		}
+		*modelVersion = version
+	}
+
+	configModel, err := syntax.GetConfigModel(*modelVersion)
+	if err != nil {
+		panic(err)
+	}
+
+	src, err := structgen.Generate(configModel, structgen.Options{
+		Package:      *pkg,
+		ModelVersion: *modelVersion,
+		Subtree:      strings.Fields(*subtree),
+		RootType:     *rootType,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if *out == "" {
+		fmt.Print(string(src))
+	} else {
+		err = os.WriteFile(*out, src, 0644)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -10,12 +10,16 @@ package configmodel
 // because that lets us get rid of the bulk of the special-case
 // handling for Node/LeafNode/TagNode.
 
+import (
+        "strings"
+)
+
 // InterfaceDefinition is the top-level definition of an config
 // setting in VyOS's XML spec.  It should really be called
 // `ConfigDefinition` or similar, but the XML tag that they use is
 // `InterfaceDefinition` so I'm sticking with that.
 type InterfaceDefinition struct {
-        Nodes []*Node `xml:"node", json:"Node"`
+        Nodes []*Node `xml:"node" json:"Node"`
 }
 
 func (id *InterfaceDefinition) VyOSConfig() *VyOSConfigNode {
@@ -46,6 +50,10 @@ func (n *Node) VyOSConfigNode() *VyOSConfigNode {
                 Type: "node",
                 Name: n.Name,
         }
+        n.Properties.help(c)
+        if n.Properties != nil {
+                c.KeepChildOrder = n.Properties.KeepChildOrder != nil
+        }
 
         if n.Children != nil {
                 c.Children = n.Children.VyOSConfigNode()
@@ -59,32 +67,120 @@ func (n *Node) VyOSConfigNode() *VyOSConfigNode {
 // other things that we don't actually care about at the moment.  It
 // also contains two booleans, `Multi` and `Valueless` that tell us
 // when a Node (or generally a LeafNode) take either multiple values
-// or no values at all.
+// or no values at all, and `Secret`, which marks values like
+// passwords that shouldn't be shown to just anyone.
 type NodeProperties struct {
         DefaultValue   string                    `xml:"defaultValue" json:"-"`
         Help           []*PropertyHelp           `xml:"help" json:"-"`
         CompletionHelp []*PropertyCompletionHelp `xml:"completionHelp" json:"-"`
         ValueHelp      []*PropertyValueHelp      `xml:"valueHelp" json:"-"`
         Constraint     []*PropertyConstraint     `xml:"constraint" json:"-"`
-        //ConstraintErrorMessage string `xml:"constraintErrorMessage,chardata"`
+        ConstraintErrorMessage string            `xml:"constraintErrorMessage" json:"-"`
         Multi     *bool `xml:"multi" json:"multi,omitempty"`
         Valueless *bool `xml:"valueless" json:"valueless,omitempty"`
+        Secret    *bool `xml:"secret" json:"secret,omitempty"`
+
+        KeepChildOrder *bool `xml:"keepChildOrder" json:"-"`
 }
 
 type PropertyHelp struct {
         Text string `xml:",chardata"`
 }
 
+// PropertyCompletionHelp models the `<completionHelp>` tag in VyOS's
+// XML config spec, which tells the VyOS CLI how to find possible
+// values.  `<list>` holds a space-separated list of fixed values,
+// `<path>` refers to values elsewhere in the config, like
+// `interfaces ethernet`, and `<script>` is a program that VyOS runs
+// to get the values.
 type PropertyCompletionHelp struct {
-        InnerXML string `xml:",innerxml"` // Just collect for now.
+        List   []string `xml:"list"`
+        Path   []string `xml:"path"`
+        Script []string `xml:"script"`
 }
 
+// PropertyValueHelp models the `<valueHelp>` tag in VyOS's XML config
+// spec, which describes one format that a value can take, like
+// `<format>u32:1-65535</format>` with a description of `Port
+// number`.
 type PropertyValueHelp struct {
-        InnerXML string `xml:",innerxml"` // Just collect for now.
+        Format      string `xml:"format"`
+        Description string `xml:"description"`
 }
 
+// PropertyConstraint models the `<constraint>` tag in VyOS's XML
+// config spec.  It holds a list of regular expressions and external
+// validator programs; a value is valid if it matches any of them.
 type PropertyConstraint struct {
-        InnerXML string `xml:",innerxml"` // Just collect for now.
+        Regex      []string             `xml:"regex"`
+        Validators []*PropertyValidator `xml:"validator"`
+}
+
+// PropertyValidator models the `<validator>` tag inside of
+// `<constraint>`, like `<validator name="numeric" argument="--range
+// 1-65535"/>`.
+type PropertyValidator struct {
+        Name     string `xml:"name,attr"`
+        Argument string `xml:"argument,attr"`
+}
+
+// constraint converts the `<constraint>` and `<constraintErrorMessage>`
+// properties into a Constraint, or returns nil if there aren't any.
+func (np *NodeProperties) constraint() *Constraint {
+        if np == nil || len(np.Constraint) == 0 {
+                return nil
+        }
+
+        c := &Constraint{
+                ErrorMessage: strings.TrimSpace(np.ConstraintErrorMessage),
+        }
+        for _, pc := range np.Constraint {
+                for _, regex := range pc.Regex {
+                        c.Regex = append(c.Regex, strings.TrimSpace(regex))
+                }
+                for _, v := range pc.Validators {
+                        c.Validators = append(c.Validators, &Validator{
+                                Name:     v.Name,
+                                Argument: v.Argument,
+                        })
+                }
+        }
+        return c
+}
+
+// help copies the `<help>`, `<valueHelp>`, and `<completionHelp>`
+// properties into `c`.
+func (np *NodeProperties) help(c *VyOSConfigNode) {
+        if np == nil {
+                return
+        }
+
+        for _, h := range np.Help {
+                if text := strings.TrimSpace(h.Text); text != "" {
+                        c.Help = text
+                }
+        }
+        for _, vh := range np.ValueHelp {
+                c.ValueHelp = append(c.ValueHelp, &ValueHelp{
+                        Format:      strings.TrimSpace(vh.Format),
+                        Description: strings.TrimSpace(vh.Description),
+                })
+        }
+        if len(np.CompletionHelp) > 0 {
+                ch := &CompletionHelp{}
+                for _, pch := range np.CompletionHelp {
+                        for _, list := range pch.List {
+                                ch.List = append(ch.List, strings.Fields(list)...)
+                        }
+                        for _, path := range pch.Path {
+                                ch.Path = append(ch.Path, strings.TrimSpace(path))
+                        }
+                        for _, script := range pch.Script {
+                                ch.Script = append(ch.Script, strings.TrimSpace(script))
+                        }
+                }
+                c.CompletionHelp = ch
+        }
 }
 
 // NodeChildren models the `<children>` tag inside of the various node
@@ -136,9 +232,13 @@ func (ln *LeafNode) VyOSConfigNode() *VyOSConfigNode {
                 if ln.Properties.Valueless != nil {
                         value = false
                 }
+                c.DefaultValue = strings.TrimSpace(ln.Properties.DefaultValue)
+                c.Secret = ln.Properties.Secret != nil
 
         }
         c.HasValue = value
+        c.Constraint = ln.Properties.constraint()
+        ln.Properties.help(c)
 
         return c
 }
@@ -163,6 +263,12 @@ func (tn *TagNode) VyOSConfigNode() *VyOSConfigNode {
         // Always true for tagnodes?
         c.Multi = true
         c.HasValue = true
+        c.Constraint = tn.Properties.constraint()
+        if tn.Properties != nil {
+                c.Secret = tn.Properties.Secret != nil
+                c.KeepChildOrder = tn.Properties.KeepChildOrder != nil
+        }
+        tn.Properties.help(c)
 
         if tn.Children != nil {
                 c.Children = tn.Children.VyOSConfigNode()
@@ -9,11 +9,66 @@ import (
 )
 
 type VyOSConfigNode struct {
-        Type     string            `json:"type,omitempty"`
-        Name     string            `json:"name"`
-        Children []*VyOSConfigNode `json:"children,omitempty"`
-        Multi    bool              `json:"multi,omitempty"`
-        HasValue bool              `json:"has_value,omitempty"`
+        Type       string            `json:"type,omitempty"`
+        Name       string            `json:"name"`
+        Children   []*VyOSConfigNode `json:"children,omitempty"`
+        Multi      bool              `json:"multi,omitempty"`
+        HasValue   bool              `json:"has_value,omitempty"`
+        Constraint *Constraint       `json:"constraint,omitempty"`
+
+        Help           string          `json:"help,omitempty"`            // One-line description, from `<help>`
+        ValueHelp      []*ValueHelp    `json:"value_help,omitempty"`      // Allowed value formats
+        CompletionHelp *CompletionHelp `json:"completion_help,omitempty"` // Where to find possible values
+
+        // DefaultValue is the value that VyOS uses for a LeafNode
+        // when it isn't in the config, from `<defaultValue>`.  For
+        // multi-value LeafNodes, it's a space-separated list.
+        DefaultValue string `json:"default_value,omitempty"`
+
+        // Secret is true for values like passwords and private keys,
+        // from `<secret/>`.
+        Secret bool `json:"secret,omitempty"`
+
+        // KeepChildOrder is true if VyOS keeps this node's children
+        // (or, for TagNodes, its entries) in the order that they
+        // were added instead of sorting them, from
+        // `<keepChildOrder/>`.
+        KeepChildOrder bool `json:"keep_child_order,omitempty"`
+}
+
+// ValueHelp describes one format that a LeafNode or TagNode value can
+// take.  Format is a VyOS format string like `txt`, `ipv4`, or
+// `u32:1-65535`, or a fixed value like `auto`.
+type ValueHelp struct {
+        Format      string `json:"format"`
+        Description string `json:"description,omitempty"`
+}
+
+// CompletionHelp says where the VyOS CLI finds possible values for a
+// LeafNode or TagNode.  List holds fixed values, Path holds config
+// paths (like `interfaces ethernet`) whose tag values are allowed,
+// and Script holds programs on the router that print values.
+type CompletionHelp struct {
+        List   []string `json:"list,omitempty"`
+        Path   []string `json:"path,omitempty"`
+        Script []string `json:"script,omitempty"`
+}
+
+// Constraint restricts the values allowed for a LeafNode or TagNode.
+// A value is valid if it matches any of the regular expressions or
+// passes any of the validators.  Regular expressions must match the
+// whole value.
+type Constraint struct {
+        Regex        []string     `json:"regex,omitempty"`
+        Validators   []*Validator `json:"validators,omitempty"`
+        ErrorMessage string       `json:"error_message,omitempty"` // VyOS's `constraintErrorMessage`
+}
+
+// Validator is a reference to one of VyOS's external validator
+// programs, like `numeric` with an argument of `--range 1-65535`.
+type Validator struct {
+        Name     string `json:"name"`
+        Argument string `json:"argument,omitempty"`
 }
 
 func (vcn *VyOSConfigNode) FindNodeByName(name string) *VyOSConfigNode {
@@ -36,6 +91,23 @@ func (vcn *VyOSConfigNode) WriteJSONFile(filename string, umask fs.FileMode) err
 
 // Merge the children of 2 nodes together
 func (vcn *VyOSConfigNode) Merge(b *VyOSConfigNode) {
+        if vcn.Constraint == nil {
+                vcn.Constraint = b.Constraint
+        }
+        if vcn.Help == "" {
+                vcn.Help = b.Help
+        }
+        if vcn.ValueHelp == nil {
+                vcn.ValueHelp = b.ValueHelp
+        }
+        if vcn.CompletionHelp == nil {
+                vcn.CompletionHelp = b.CompletionHelp
+        }
+        if vcn.DefaultValue == "" {
+                vcn.DefaultValue = b.DefaultValue
+        }
+        vcn.Secret = vcn.Secret || b.Secret
+        vcn.KeepChildOrder = vcn.KeepChildOrder || b.KeepChildOrder
 OUTER:
         for _, node2 := range b.Children {
                 for _, node1 := range vcn.Children {
@@ -5,4 +5,5 @@ go 1.22.2
 require (
 	github.com/hexops/gotextdiff v1.0.3
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,8 +1,11 @@
 package parser
 
 import (
-        "slices"
         "cmp"
+        "maps"
+        "slices"
+        "strings"
+
         "github.com/scottlaird/vyos-parser/configmodel"
 )
 
@@ -15,6 +18,27 @@ const (
 
 type VyOSConfigAST struct {
         Child *Node
+
+        // ConfigModel is the config model that the AST was parsed
+        // with.
+        ConfigModel *configmodel.VyOSConfigNode
+
+        // ComponentVersions holds the per-component migration
+        // versions from the `// vyos-config-version:` footer at the
+        // end of config.boot, keyed by component name.
+        ComponentVersions map[string]int
+
+        // ReleaseVersion is the VyOS release that wrote the config,
+        // from the `// Release version:` footer line.
+        ReleaseVersion string
+}
+
+// ComponentVersion returns the migration version of a single
+// component (`bgp`, `interfaces`, etc) from the config's version
+// footer, and false if the component isn't listed.
+func (vca *VyOSConfigAST) ComponentVersion(name string) (int, bool) {
+        version, ok := vca.ComponentVersions[name]
+        return version, ok
 }
 
 func (vca *VyOSConfigAST) TreeSize() int {
@@ -30,6 +54,7 @@ type Node struct {
         Type string
         Value *string
         Children []*Node
+        Comment string // Set via VyOS's `comment` command; empty if none
 }
 
 func (n *Node) TreeSize() int {
@@ -41,21 +66,102 @@ func (n *Node) TreeSize() int {
         return size
 }
 
+// Sort puts the children of `n`, and everything underneath it, in
+// the same order that VyOS uses.  Names and values are compared
+// naturally, so numbers inside of them are compared by value: `rule
+// 95` comes before `rule 900`, and `eth2` before `eth10`.  Nodes
+// marked with `<keepChildOrder/>` in the config model keep their
+// children (or, for TagNodes, their entries) in their existing
+// order.
 func (n *Node) Sort() {
-        slices.SortFunc(n.Children, func(a, b *Node) int {
-                names := cmp.Compare(a.ContextNode.Name, b.ContextNode.Name)
-                if names != 0 {
-                        return names
+        // A TagNode's entries share its ContextNode, but only the
+        // order of the entries themselves is kept.
+        if n.ContextNode == nil || !n.ContextNode.KeepChildOrder || n.Type == "tagnode" {
+                slices.SortStableFunc(n.Children, func(a, b *Node) int {
+                        names := naturalCompare(a.ContextNode.Name, b.ContextNode.Name)
+                        if names != 0 {
+                                return names
+                        }
+                        if a.ContextNode.KeepChildOrder {
+                                return 0
+                        }
+                        if a.Value != nil && b.Value != nil {
+                                return naturalCompare(*a.Value, *b.Value)
+                        }
+                        return 0
+                })
+        }
+
+        for _, child := range n.Children {
+                child.Sort()
+        }
+}
+
+// naturalCompare compares two strings like cmp.Compare, except that
+// runs of digits are compared by their numeric value, like VyOS's
+// `lexical_numeric_compare`.  Strings that only differ in leading
+// zeros fall back to a plain string comparison, so the order is
+// always consistent.
+func naturalCompare(a, b string) int {
+        i, j := 0, 0
+        for i < len(a) && j < len(b) {
+                if isDigit(a[i]) && isDigit(b[j]) {
+                        starti, startj := i, j
+                        for i < len(a) && isDigit(a[i]) {
+                                i++
+                        }
+                        for j < len(b) && isDigit(b[j]) {
+                                j++
+                        }
+                        numa := strings.TrimLeft(a[starti:i], "0")
+                        numb := strings.TrimLeft(b[startj:j], "0")
+                        if c := cmp.Compare(len(numa), len(numb)); c != 0 {
+                                return c
+                        }
+                        if c := cmp.Compare(numa, numb); c != 0 {
+                                return c
+                        }
+                        continue
                 }
-                if a.Value != nil && b.Value != nil {
-                        return cmp.Compare(*a.Value, *b.Value)
+                if c := cmp.Compare(a[i], b[j]); c != 0 {
+                        return c
                 }
-                return 0
-        })
+                i++
+                j++
+        }
+        if c := cmp.Compare(len(a)-i, len(b)-j); c != 0 {
+                return c
+        }
+        return cmp.Compare(a, b)
+}
 
+func isDigit(c byte) bool {
+        return c >= '0' && c <= '9'
+}
+
+// Copy returns a deep copy of the AST.
+func (vca *VyOSConfigAST) Copy() *VyOSConfigAST {
+        c := *vca
+        c.Child = vca.Child.Copy()
+        if vca.ComponentVersions != nil {
+                c.ComponentVersions = maps.Clone(vca.ComponentVersions)
+        }
+        return &c
+}
+
+// Copy returns a deep copy of `n` and everything underneath it.  The
+// config model is shared, not copied.
+func (n *Node) Copy() *Node {
+        c := *n
+        if n.Value != nil {
+                value := *n.Value
+                c.Value = &value
+        }
+        c.Children = nil
         for _, child := range n.Children {
-                child.Sort()
+                c.Children = append(c.Children, child.Copy())
         }
+        return &c
 }
 
 func newASTNode(contextNode *configmodel.VyOSConfigNode) *Node {
@@ -92,3 +198,73 @@ func (n *Node) addNode(contextNode *configmodel.VyOSConfigNode, value *string) *
         n.Children = append(n.Children, child)
         return child
 }
+
+// matches returns true if `n` is the AST node described by `pe`.  If
+// `pe` doesn't have a value, then any value matches.
+func (n *Node) matches(pe *PathElement) bool {
+        if n.ContextNode == nil || n.ContextNode.Name != pe.ContextNode.Name {
+                return false
+        }
+        if pe.Value == nil {
+                return true
+        }
+        return n.Value != nil && *n.Value == *pe.Value
+}
+
+// deletePath removes the node(s) described by `path` from underneath
+// `n`.  If the final element of the path doesn't have a value, then
+// every node with that name is removed; otherwise only the node with
+// the matching value is removed.  Plain nodes that are left empty are
+// removed as well.  It returns false if nothing matched.
+func (n *Node) deletePath(path []*PathElement) bool {
+        if len(path) == 0 {
+                return false
+        }
+
+        pe := path[0]
+        if len(path) > 1 {
+                for i, child := range n.Children {
+                        if child.matches(pe) {
+                                if !child.deletePath(path[1:]) {
+                                        return false
+                                }
+                                // Like VyOS, don't leave empty
+                                // non-tag nodes behind.
+                                if len(child.Children) == 0 && child.Type == "node" {
+                                        n.Children = slices.Delete(n.Children, i, i+1)
+                                }
+                                return true
+                        }
+                }
+                return false
+        }
+
+        children := []*Node{}
+        for _, child := range n.Children {
+                if !child.matches(pe) {
+                        children = append(children, child)
+                }
+        }
+        if len(children) == len(n.Children) {
+                return false
+        }
+        n.Children = children
+        return true
+}
+
+// findPath returns the node described by `path`, or nil if it doesn't
+// exist.
+func (n *Node) findPath(path []*PathElement) *Node {
+        node := n
+OUTER:
+        for _, pe := range path {
+                for _, child := range node.Children {
+                        if child.matches(pe) {
+                                node = child
+                                continue OUTER
+                        }
+                }
+                return nil
+        }
+        return node
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+        "encoding/xml"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+func TestNaturalCompare(t *testing.T) {
+        tests := []struct {
+                a, b string
+                want int
+        }{
+                {"10", "95", -1},
+                {"95", "900", -1},
+                {"eth2", "eth10", -1},
+                {"eth10", "eth2", 1},
+                {"eth1", "eth1.10", -1},
+                {"eth1.2", "eth1.10", -1},
+                {"10.1.0.9", "10.1.0.10", -1},
+                {"abc", "abd", -1},
+                {"a", "a1", -1},
+                {"007", "7", -1},
+                {"7", "7", 0},
+        }
+        for _, test := range tests {
+                if got := naturalCompare(test.a, test.b); got != test.want {
+                        t.Errorf("naturalCompare(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+                }
+        }
+}
+
+func TestSortNatural(t *testing.T) {
+        configModel := getConfigModel(t)
+        ast, err := ParseSetFormat(`set firewall ipv4 forward filter rule 900 action 'drop'
+set firewall ipv4 forward filter rule 95 action 'accept'
+set firewall ipv4 forward filter rule 10 action 'accept'
+set interfaces ethernet eth10 description 'ten'
+set interfaces ethernet eth2 description 'two'
+set interfaces ethernet eth1 description 'one'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        ast.Sort()
+
+        got, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := `set firewall ipv4 forward filter rule 10 action 'accept'
+set firewall ipv4 forward filter rule 95 action 'accept'
+set firewall ipv4 forward filter rule 900 action 'drop'
+set interfaces ethernet eth1 description 'one'
+set interfaces ethernet eth2 description 'two'
+set interfaces ethernet eth10 description 'ten'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected sorted output:\n%s", diff)
+        }
+}
+
+func TestSortKeepChildOrder(t *testing.T) {
+        id := &configmodel.InterfaceDefinition{}
+        err := xml.Unmarshal([]byte(`<interfaceDefinition>
+  <node name="policy">
+    <children>
+      <tagNode name="prefix-list">
+        <children>
+          <tagNode name="rule">
+            <properties>
+              <keepChildOrder/>
+            </properties>
+            <children>
+              <leafNode name="action"/>
+              <leafNode name="prefix"/>
+            </children>
+          </tagNode>
+        </children>
+      </tagNode>
+    </children>
+  </node>
+</interfaceDefinition>`), id)
+        if err != nil {
+                t.Fatalf("Failed to parse XML: %v", err)
+        }
+        configModel := id.VyOSConfig()
+        rule := configModel.FindNodeByName("policy").FindNodeByName("prefix-list").FindNodeByName("rule")
+        if !rule.KeepChildOrder {
+                t.Fatalf("Expected KeepChildOrder to be set")
+        }
+
+        ast, err := ParseSetFormat(`set policy prefix-list B rule 20 prefix '192.0.2.0/24'
+set policy prefix-list B rule 20 action 'permit'
+set policy prefix-list B rule 5 action 'deny'
+set policy prefix-list A rule 1 action 'permit'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        ast.Sort()
+
+        // The prefix lists and the leaves inside of each rule are
+        // sorted, but the rules themselves aren't.
+        got, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := `set policy prefix-list A rule 1 action 'permit'
+set policy prefix-list B rule 20 action 'permit'
+set policy prefix-list B rule 20 prefix '192.0.2.0/24'
+set policy prefix-list B rule 5 action 'deny'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected sorted output:\n%s", diff)
+        }
+}
+
+// findKeepChildOrder returns the path to the first TagNode in
+// `configNode` with KeepChildOrder set, or nil if there isn't one.
+func findKeepChildOrder(configNode *configmodel.VyOSConfigNode) []*configmodel.VyOSConfigNode {
+        for _, child := range configNode.Children {
+                if child.Type == "tagnode" && child.KeepChildOrder {
+                        return []*configmodel.VyOSConfigNode{child}
+                }
+                if path := findKeepChildOrder(child); path != nil {
+                        return append([]*configmodel.VyOSConfigNode{child}, path...)
+                }
+        }
+        return nil
+}
+
+func TestSortKeepChildOrderDefaultModel(t *testing.T) {
+        configModel := getConfigModel(t)
+        path := findKeepChildOrder(configModel)
+        if path == nil {
+                t.Skip("Embedded config model has no keepChildOrder TagNodes; regenerate syntax/*.json.gz")
+        }
+
+        // Build `set` lines for two entries of the TagNode, in the
+        // opposite of their natural order, using `a` as the value
+        // for any TagNodes above it.
+        prefix := "set"
+        for _, n := range path[:len(path)-1] {
+                prefix += " " + n.Name
+                if n.Type == "tagnode" {
+                        prefix += " a"
+                }
+        }
+        prefix += " " + path[len(path)-1].Name
+        config := prefix + " 20\n" + prefix + " 5\n"
+
+        ast, err := ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        ast.Sort()
+
+        got, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        if got != config {
+                edits := myers.ComputeEdits("want", config, got)
+                diff := gotextdiff.ToUnified("want", "got", config, edits)
+                t.Errorf("Sort reordered keepChildOrder entries:\n%s", diff)
+        }
+}
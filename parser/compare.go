@@ -0,0 +1,212 @@
+package parser
+
+import (
+        "strings"
+)
+
+// CompareOptions controls the output of WriteCompareFormat.
+type CompareOptions struct {
+        // Context is the number of unchanged siblings to show on
+        // either side of each change.  The blocks that enclose a
+        // change are always shown.
+        Context int
+}
+
+// WriteCompareFormat returns the differences between two config ASTs
+// in the format used by VyOS's `compare` command: `show` format, with
+// the first column of each line holding `+` for added lines, `-` for
+// deleted lines, and a space for unchanged lines.  A LeafNode whose
+// value changed is shown as a deleted line followed by an added
+// line.  It returns an empty string if the configs are the same.
+// `options` may be nil.
+func WriteCompareFormat(from, to *VyOSConfigAST, options *CompareOptions) (string, error) {
+        if options == nil {
+                options = &CompareOptions{}
+        }
+        results, _, err := writeComparePartial(from.Child, to.Child, 1, options)
+        if err != nil {
+                return "", err
+        }
+        if len(results) == 0 {
+                return "", nil
+        }
+        return strings.Join(results, "\n") + "\n", nil
+}
+
+// WriteCompareCommands returns the differences between two config
+// ASTs in the format used by VyOS's `compare commands`: the `delete`
+// and `set` commands that turn `from` into `to`.
+func WriteCompareCommands(from, to *VyOSConfigAST) string {
+        commands := DiffCommands(from, to)
+        if len(commands) == 0 {
+                return ""
+        }
+        return WriteSetCommands(commands)
+}
+
+// nodePair is a pair of matching nodes from two ASTs.  Either side
+// may be nil if the node only exists in one of them.
+type nodePair struct {
+        from, to *Node
+}
+
+// pairChildren matches up the children of two nodes, in the order
+// that they appear in `to`.  Children that only exist in `from` are
+// placed where they appeared in `from`.
+func pairChildren(from, to *Node) []nodePair {
+        fromIndex := map[string]int{}
+        for i, child := range from.Children {
+                fromIndex[diffKey(child)] = i
+        }
+        toKeys := map[string]bool{}
+        for _, child := range to.Children {
+                toKeys[diffKey(child)] = true
+        }
+
+        pairs := []nodePair{}
+        next := 0 // The next child of `from` that hasn't been placed
+        flushDeleted := func(end int) {
+                for ; next < end; next++ {
+                        if !toKeys[diffKey(from.Children[next])] {
+                                pairs = append(pairs, nodePair{from: from.Children[next]})
+                        }
+                }
+        }
+
+        for _, child := range to.Children {
+                i, ok := fromIndex[diffKey(child)]
+                if !ok {
+                        // Put deletions before additions at the
+                        // same spot, like `diff` does.
+                        for next < len(from.Children) && !toKeys[diffKey(from.Children[next])] {
+                                flushDeleted(next + 1)
+                        }
+                        pairs = append(pairs, nodePair{to: child})
+                        continue
+                }
+                flushDeleted(i)
+                if next == i {
+                        next++
+                }
+                pairs = append(pairs, nodePair{from: from.Children[i], to: child})
+        }
+        flushDeleted(len(from.Children))
+        return pairs
+}
+
+// markLines replaces the first column of each line with `marker`.
+// Lines from writeShowPartial always start with a space, and
+// multi-line comments are a single entry with embedded newlines.
+func markLines(lines []string, marker string) []string {
+        for i, line := range lines {
+                parts := strings.Split(line, "\n")
+                for j, part := range parts {
+                        parts[j] = marker + part[1:]
+                }
+                lines[i] = strings.Join(parts, "\n")
+        }
+        return lines
+}
+
+// writeComparePartial writes the differences between the children of
+// two matching nodes.  It returns the lines to print and true if
+// anything changed.
+func writeComparePartial(from, to *Node, indent int, options *CompareOptions) ([]string, bool, error) {
+        pairs := pairChildren(from, to)
+        rendered := make([][]string, len(pairs))
+        changed := make([]bool, len(pairs))
+        anyChanged := false
+
+        for i, pair := range pairs {
+                lines, c, err := writeComparePair(pair, indent, options)
+                if err != nil {
+                        return nil, false, err
+                }
+                rendered[i] = lines
+                changed[i] = c
+                anyChanged = anyChanged || c
+        }
+        if !anyChanged {
+                return nil, false, nil
+        }
+
+        results := []string{}
+        for i := range pairs {
+                show := false
+                for j := max(0, i-options.Context); j <= min(len(pairs)-1, i+options.Context); j++ {
+                        if changed[j] {
+                                show = true
+                                break
+                        }
+                }
+                if show {
+                        results = append(results, rendered[i]...)
+                }
+        }
+        return results, true, nil
+}
+
+// writeComparePair writes a single pair of matching nodes, returning
+// the lines to print and true if anything changed.
+func writeComparePair(pair nodePair, indent int, options *CompareOptions) ([]string, bool, error) {
+        if pair.to == nil {
+                lines, err := writeShowPartial(pair.from, indent)
+                return markLines(lines, "-"), true, err
+        }
+        if pair.from == nil {
+                lines, err := writeShowPartial(pair.to, indent)
+                return markLines(lines, "+"), true, err
+        }
+
+        commentChanged := pair.from.Comment != pair.to.Comment
+        if pair.to.Type == "leafnode" {
+                if !equalValues(pair.from.Value, pair.to.Value) {
+                        fromLines, err := writeShowPartial(pair.from, indent)
+                        if err != nil {
+                                return nil, false, err
+                        }
+                        toLines, err := writeShowPartial(pair.to, indent)
+                        if err != nil {
+                                return nil, false, err
+                        }
+                        return append(markLines(fromLines, "-"), markLines(toLines, "+")...), true, nil
+                }
+                results := compareComments(pair, indent)
+                results = append(results, spaces(indent)+showLine(pair.to))
+                return results, commentChanged, nil
+        }
+
+        children, childrenChanged, err := writeComparePartial(pair.from, pair.to, indent+4, options)
+        if err != nil {
+                return nil, false, err
+        }
+        if !childrenChanged && !commentChanged {
+                lines, err := writeShowPartial(pair.to, indent)
+                return lines, false, err
+        }
+
+        results := compareComments(pair, indent)
+        results = append(results, spaces(indent)+showLine(pair.to)+" {")
+        results = append(results, children...)
+        results = append(results, spaces(indent)+"}")
+        return results, true, nil
+}
+
+// compareComments returns the comment lines for a pair of matching
+// nodes, marked as deleted and added if the comment changed.
+func compareComments(pair nodePair, indent int) []string {
+        results := []string{}
+        if pair.from.Comment == pair.to.Comment {
+                if pair.to.Comment != "" {
+                        results = append(results, writeComment(pair.to.Comment, indent))
+                }
+                return results
+        }
+        if pair.from.Comment != "" {
+                results = append(results, markLines([]string{writeComment(pair.from.Comment, indent)}, "-")...)
+        }
+        if pair.to.Comment != "" {
+                results = append(results, markLines([]string{writeComment(pair.to.Comment, indent)}, "+")...)
+        }
+        return results
+}
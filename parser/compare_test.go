@@ -0,0 +1,138 @@
+package parser
+
+import (
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+const compareFromConfig = `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth0 description 'old'
+set interfaces ethernet eth0 hw-id '00:11:22:33:44:55'
+set interfaces ethernet eth0 mtu '1500'
+set interfaces ethernet eth1 address 'dhcp'
+set interfaces ethernet eth2 hw-id '00:11:22:33:44:57'
+set system host-name 'router'
+`
+
+const compareToConfig = `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.3/24'
+set interfaces ethernet eth0 description 'new'
+set interfaces ethernet eth0 hw-id '00:11:22:33:44:55'
+set interfaces ethernet eth0 mtu '1500'
+set interfaces ethernet eth2 hw-id '00:11:22:33:44:57'
+set interfaces ethernet eth3 address 'dhcp'
+set system host-name 'router'
+comment system host-name 'Do not change'
+`
+
+func TestWriteCompareFormat(t *testing.T) {
+        configModel := getConfigModel(t)
+        from, err := ParseSetFormat(compareFromConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse from config: %v", err)
+        }
+        to, err := ParseSetFormat(compareToConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse to config: %v", err)
+        }
+
+        tests := []struct {
+                context int
+                want    string
+        }{
+                {
+                        context: 0,
+                        want: ` interfaces {
+     ethernet eth0 {
+-        address 10.0.0.2/24
++        address 10.0.0.3/24
+-        description old
++        description new
+     }
+-    ethernet eth1 {
+-        address dhcp
+-    }
++    ethernet eth3 {
++        address dhcp
++    }
+ }
+ system {
++    /* Do not change */
+     host-name router
+ }
+`,
+                },
+                {
+                        context: 1,
+                        want: ` interfaces {
+     ethernet eth0 {
+         address 10.0.0.1/24
+-        address 10.0.0.2/24
++        address 10.0.0.3/24
+-        description old
++        description new
+         hw-id 00:11:22:33:44:55
+     }
+-    ethernet eth1 {
+-        address dhcp
+-    }
+     ethernet eth2 {
+         hw-id 00:11:22:33:44:57
+     }
++    ethernet eth3 {
++        address dhcp
++    }
+ }
+ system {
++    /* Do not change */
+     host-name router
+ }
+`,
+                },
+        }
+
+        for _, test := range tests {
+                got, err := WriteCompareFormat(from, to, &CompareOptions{Context: test.context})
+                if err != nil {
+                        t.Fatalf("WriteCompareFormat failed: %v", err)
+                }
+                if got != test.want {
+                        edits := myers.ComputeEdits("want", test.want, got)
+                        diff := gotextdiff.ToUnified("want", "got", test.want, edits)
+                        t.Errorf("Unexpected compare output with context %d:\n%s", test.context, diff)
+                }
+        }
+
+        if got, _ := WriteCompareFormat(to, to, nil); got != "" {
+                t.Errorf("Expected no output for identical configs, got:\n%s", got)
+        }
+}
+
+func TestWriteCompareCommands(t *testing.T) {
+        configModel := getConfigModel(t)
+        from, err := ParseSetFormat(compareFromConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse from config: %v", err)
+        }
+        to, err := ParseSetFormat(compareToConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse to config: %v", err)
+        }
+
+        got := WriteCompareCommands(from, to)
+        want := `delete interfaces ethernet eth0 address '10.0.0.2/24'
+delete interfaces ethernet eth1
+set interfaces ethernet eth0 description 'new'
+set interfaces ethernet eth0 address '10.0.0.3/24'
+set interfaces ethernet eth3 address 'dhcp'
+comment system host-name 'Do not change'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected compare commands:\n%s", diff)
+        }
+}
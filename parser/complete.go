@@ -0,0 +1,190 @@
+package parser
+
+import (
+        "slices"
+        "strings"
+        "unicode"
+
+        "github.com/kballard/go-shellquote"
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// Completion is one possible next word for a partial `set` line.
+type Completion struct {
+        Type string // CompletionWord, CompletionValue, or CompletionFormat
+        Word string // The word itself, or a value format like `u32:1-65535`
+        Help string // VyOS's help text, if any
+}
+
+const (
+        CompletionWord   = "word"   // Word is the name of a child node
+        CompletionValue  = "value"  // Word is a possible value for a LeafNode or TagNode
+        CompletionFormat = "format" // Word describes the format of a value, and isn't meant to be typed in
+)
+
+// Complete returns the possible next words for a partial `set` line,
+// like `set interfaces ethernet eth0 du`, using the help text and
+// completion lists from `configModel`.  If the line ends with a
+// space, then every possible next word is returned; otherwise only
+// words that start with the last, unfinished word are returned.  The
+// leading `set` is optional.
+//
+// Values come from VyOS's `<completionHelp><list>`, and each
+// `<valueHelp>` that doesn't describe one of those values is returned
+// as a CompletionFormat.  A *ParseError is returned if the line
+// contains a word that isn't valid.
+func Complete(line string, configModel *configmodel.VyOSConfigNode) ([]*Completion, error) {
+        return complete(line, configModel, nil)
+}
+
+// Complete is like the Complete function, but it also returns values
+// that are already in the config: existing TagNode entries, and the
+// entries of any config paths that VyOS's `<completionHelp><path>`
+// refers to, like the names of Ethernet interfaces.
+func (vca *VyOSConfigAST) Complete(line string) ([]*Completion, error) {
+        return complete(line, vca.ConfigModel, vca)
+}
+
+// complete implements Complete.  `ast` may be nil.
+func complete(line string, configModel *configmodel.VyOSConfigNode, ast *VyOSConfigAST) ([]*Completion, error) {
+        fields, partial := splitPartialLine(line)
+        columns := fieldColumns(line)
+        if len(fields) > 0 && fields[0] == "set" {
+                fields = fields[1:]
+                columns = columns[min(1, len(columns)):]
+        }
+
+        configNode := configModel
+        var astNode *Node
+        if ast != nil {
+                astNode = ast.Child
+        }
+        path := []string{}
+        needValue := false
+
+        for i, field := range fields {
+                if needValue {
+                        needValue = false
+                        path = append(path, field)
+                        astNode = findChild(astNode, configNode, &field, false)
+                        continue
+                }
+
+                child := configNode.FindNodeByName(field)
+                if child == nil {
+                        return nil, newUnknownWordError(0, columnOf(columns, i), path, field, configNode)
+                }
+                configNode = child
+                path = append(path, field)
+                if configNode.HasValue {
+                        needValue = true
+                } else {
+                        astNode = findChild(astNode, configNode, nil, false)
+                }
+        }
+
+        completions := []*Completion{}
+        if needValue {
+                completions = valueCompletions(configNode, astNode, ast, partial)
+        } else {
+                for _, child := range configNode.Children {
+                        if strings.HasPrefix(child.Name, partial) {
+                                completions = append(completions, &Completion{
+                                        Type: CompletionWord,
+                                        Word: child.Name,
+                                        Help: child.Help,
+                                })
+                        }
+                }
+        }
+        return completions, nil
+}
+
+// valueCompletions returns the possible values for `configNode`.
+// `parent` is the AST node that holds existing values, if any.
+func valueCompletions(configNode *configmodel.VyOSConfigNode, parent *Node, ast *VyOSConfigAST, partial string) []*Completion {
+        completions := []*Completion{}
+        seen := map[string]bool{}
+        addValue := func(value, help string) {
+                if seen[value] || !strings.HasPrefix(value, partial) {
+                        return
+                }
+                seen[value] = true
+                completions = append(completions, &Completion{
+                        Type: CompletionValue,
+                        Word: value,
+                        Help: help,
+                })
+        }
+
+        // VyOS usually has a valueHelp with the same format as each
+        // fixed value, to describe it.
+        valueHelp := map[string]string{}
+        for _, vh := range configNode.ValueHelp {
+                valueHelp[vh.Format] = vh.Description
+        }
+
+        ch := configNode.CompletionHelp
+        if ch != nil {
+                for _, value := range ch.List {
+                        addValue(value, valueHelp[value])
+                }
+        }
+
+        if parent != nil {
+                for _, n := range parent.Children {
+                        if n.ContextNode != nil && n.ContextNode.Name == configNode.Name && n.Value != nil {
+                                addValue(*n.Value, "")
+                        }
+                }
+        }
+        if ast != nil && ch != nil {
+                for _, path := range ch.Path {
+                        values, err := ast.Get(path)
+                        if err != nil {
+                                // The path is from the config model, so it
+                                // should always be valid.
+                                continue
+                        }
+                        for _, value := range values {
+                                addValue(value, "")
+                        }
+                }
+        }
+
+        for _, vh := range configNode.ValueHelp {
+                if ch != nil && slices.Contains(ch.List, vh.Format) {
+                        continue
+                }
+                completions = append(completions, &Completion{
+                        Type: CompletionFormat,
+                        Word: vh.Format,
+                        Help: vh.Description,
+                })
+        }
+        return completions
+}
+
+// splitPartialLine splits a partial `set` line into complete words
+// and the unfinished last word, which is empty if the line ends with
+// whitespace.  An unterminated quote is treated as part of the
+// unfinished word.
+func splitPartialLine(line string) ([]string, string) {
+        fields, err := shellquote.Split(line)
+        if err != nil {
+                // Probably an unterminated quote or trailing
+                // backslash; try finishing it off.
+                for _, end := range []string{"'", `"`, `\`} {
+                        fields, err = shellquote.Split(line + end)
+                        if err == nil && len(fields) > 0 {
+                                return fields[:len(fields)-1], fields[len(fields)-1]
+                        }
+                }
+                return nil, ""
+        }
+
+        if len(fields) == 0 || line == "" || unicode.IsSpace(rune(line[len(line)-1])) {
+                return fields, ""
+        }
+        return fields[:len(fields)-1], fields[len(fields)-1]
+}
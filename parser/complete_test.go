@@ -0,0 +1,371 @@
+package parser
+
+import (
+        "encoding/xml"
+        "errors"
+        "fmt"
+        "slices"
+        "testing"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// completeTestXML is a trimmed-down interface definition with help
+// text and completion lists.
+const completeTestXML = `<?xml version="1.0"?>
+<interfaceDefinition>
+  <node name="interfaces">
+    <properties>
+      <help>Network interfaces</help>
+    </properties>
+    <children>
+      <tagNode name="ethernet">
+        <properties>
+          <help>Ethernet Interface</help>
+          <valueHelp>
+            <format>ethN</format>
+            <description>Ethernet interface name</description>
+          </valueHelp>
+        </properties>
+        <children>
+          <leafNode name="description">
+            <properties>
+              <help>Description</help>
+              <valueHelp>
+                <format>txt</format>
+                <description>Description</description>
+              </valueHelp>
+            </properties>
+          </leafNode>
+          <leafNode name="disable">
+            <properties>
+              <help>Administratively disable interface</help>
+              <valueless/>
+            </properties>
+          </leafNode>
+          <leafNode name="duplex">
+            <properties>
+              <help>Duplex mode</help>
+              <completionHelp>
+                <list>auto half full</list>
+              </completionHelp>
+              <valueHelp>
+                <format>auto</format>
+                <description>Auto negotiation</description>
+              </valueHelp>
+              <valueHelp>
+                <format>half</format>
+                <description>Half duplex</description>
+              </valueHelp>
+              <valueHelp>
+                <format>full</format>
+                <description>Full duplex</description>
+              </valueHelp>
+            </properties>
+          </leafNode>
+          <leafNode name="mtu">
+            <properties>
+              <help>Maximum Transmission Unit (MTU)</help>
+              <valueHelp>
+                <format>u32:68-16000</format>
+                <description>Maximum Transmission Unit in byte</description>
+              </valueHelp>
+            </properties>
+          </leafNode>
+        </children>
+      </tagNode>
+    </children>
+  </node>
+  <node name="service">
+    <children>
+      <node name="lldp">
+        <properties>
+          <help>LLDP settings</help>
+        </properties>
+        <children>
+          <tagNode name="interface">
+            <properties>
+              <help>Location data for interface</help>
+              <completionHelp>
+                <list>all</list>
+                <script>${vyos_completion_dir}/list_interfaces</script>
+                <path>interfaces ethernet</path>
+              </completionHelp>
+            </properties>
+            <children>
+              <leafNode name="disable">
+                <properties>
+                  <help>Disable LLDP on this interface</help>
+                  <valueless/>
+                </properties>
+              </leafNode>
+            </children>
+          </tagNode>
+        </children>
+      </node>
+    </children>
+  </node>
+</interfaceDefinition>
+`
+
+// getCompleteTestModel returns the config model from completeTestXML.
+func getCompleteTestModel(t *testing.T) *configmodel.VyOSConfigNode {
+        id := &configmodel.InterfaceDefinition{}
+        if err := xml.Unmarshal([]byte(completeTestXML), id); err != nil {
+                t.Fatalf("Failed to parse XML: %v", err)
+        }
+        return id.VyOSConfig()
+}
+
+// getHelpNode returns the node at `path` in the default config model,
+// skipping the test if the embedded model was generated before help
+// text was added to it.
+func getHelpNode(t *testing.T, configModel *configmodel.VyOSConfigNode, path ...string) *configmodel.VyOSConfigNode {
+        n := getConfigNode(t, configModel, path...)
+        if n.Help == "" {
+                t.Skipf("Embedded config model has no help for %v; regenerate syntax/*.json.gz", path)
+        }
+        return n
+}
+
+// completionStrings formats completions for easy comparison.
+func completionStrings(completions []*Completion) []string {
+        results := []string{}
+        for _, c := range completions {
+                results = append(results, fmt.Sprintf("%s %s: %s", c.Type, c.Word, c.Help))
+        }
+        return results
+}
+
+// completionWords returns the words of the completions with type `typ`.
+func completionWords(completions []*Completion, typ string) []string {
+        results := []string{}
+        for _, c := range completions {
+                if c.Type == typ {
+                        results = append(results, c.Word)
+                }
+        }
+        return results
+}
+
+func TestHelpModel(t *testing.T) {
+        configModel := getCompleteTestModel(t)
+        duplex := configModel.FindNodeByName("interfaces").FindNodeByName("ethernet").FindNodeByName("duplex")
+        if duplex.Help != "Duplex mode" {
+                t.Errorf("Help is %q, want %q", duplex.Help, "Duplex mode")
+        }
+        if len(duplex.ValueHelp) != 3 || duplex.ValueHelp[1].Format != "half" || duplex.ValueHelp[1].Description != "Half duplex" {
+                t.Errorf("Unexpected ValueHelp: %+v", duplex.ValueHelp)
+        }
+        if duplex.CompletionHelp == nil || fmt.Sprint(duplex.CompletionHelp.List) != "[auto half full]" {
+                t.Errorf("Unexpected CompletionHelp: %+v", duplex.CompletionHelp)
+        }
+
+        lldp := configModel.FindNodeByName("service").FindNodeByName("lldp").FindNodeByName("interface")
+        if lldp.CompletionHelp == nil || fmt.Sprint(lldp.CompletionHelp.Path) != "[interfaces ethernet]" || fmt.Sprint(lldp.CompletionHelp.Script) != "[${vyos_completion_dir}/list_interfaces]" {
+                t.Errorf("Unexpected CompletionHelp: %+v", lldp.CompletionHelp)
+        }
+}
+
+func TestComplete(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        tests := []struct {
+                line string
+                want []string
+        }{
+                {"set int", []string{"interfaces"}},
+                {"int", []string{"interfaces"}},
+                {"set service ss", []string{"ssh"}},
+                {"set interfaces ethernet eth0 du", []string{"duplex"}},
+                {"set interfaces ethernet eth0 disable-f", []string{"disable-flow-control"}},
+                {"set interfaces ethernet eth0 disable-flow-control ", []string{}},
+                {"set interfaces ethernet eth0 mtu ", []string{}},
+        }
+        for _, test := range tests {
+                completions, err := Complete(test.line, configModel)
+                if err != nil {
+                        t.Errorf("Complete(%q) returned error: %v", test.line, err)
+                        continue
+                }
+                got := completionWords(completions, CompletionWord)
+                if !slices.Equal(got, test.want) {
+                        t.Errorf("Complete(%q) = %q, want %q", test.line, got, test.want)
+                }
+        }
+
+        completions, err := Complete("set ", configModel)
+        if err != nil {
+                t.Fatalf("Complete returned error: %v", err)
+        }
+        if got := completionWords(completions, CompletionWord); !slices.Contains(got, "interfaces") || !slices.Contains(got, "system") {
+                t.Errorf("Complete(%q) = %q, want every top-level node", "set ", got)
+        }
+}
+
+func TestCompleteHelp(t *testing.T) {
+        configModel := getCompleteTestModel(t)
+
+        tests := []struct {
+                line string
+                want []string
+        }{
+                {"", []string{"word interfaces: Network interfaces", "word service: "}},
+                {"set ", []string{"word interfaces: Network interfaces", "word service: "}},
+                {"set int", []string{"word interfaces: Network interfaces"}},
+                {"set interfaces ethernet ", []string{"format ethN: Ethernet interface name"}},
+                {"set interfaces ethernet eth0 d", []string{
+                        "word description: Description",
+                        "word disable: Administratively disable interface",
+                        "word duplex: Duplex mode",
+                }},
+                {"set interfaces ethernet eth0 duplex ", []string{
+                        "value auto: Auto negotiation",
+                        "value half: Half duplex",
+                        "value full: Full duplex",
+                }},
+                {"set interfaces ethernet eth0 duplex h", []string{"value half: Half duplex"}},
+                {"set interfaces ethernet eth0 mtu ", []string{"format u32:68-16000: Maximum Transmission Unit in byte"}},
+                {"set interfaces ethernet eth0 description 'A", []string{"format txt: Description"}},
+                {"set interfaces ethernet eth0 disable ", []string{}},
+                {"set service lldp interface ", []string{"value all: "}},
+        }
+        for _, test := range tests {
+                completions, err := Complete(test.line, configModel)
+                if err != nil {
+                        t.Errorf("Complete(%q) returned error: %v", test.line, err)
+                        continue
+                }
+                got := completionStrings(completions)
+                if fmt.Sprint(got) != fmt.Sprint(test.want) {
+                        t.Errorf("Complete(%q) = %q, want %q", test.line, got, test.want)
+                }
+        }
+}
+
+func TestCompleteHelpDefaultModel(t *testing.T) {
+        configModel := getConfigModel(t)
+        getHelpNode(t, configModel, "interfaces", "ethernet", "duplex")
+
+        completions, err := Complete("set interfaces ethernet eth0 du", configModel)
+        if err != nil {
+                t.Fatalf("Complete returned error: %v", err)
+        }
+        if len(completions) != 1 || completions[0].Help == "" {
+                t.Errorf("Expected help for duplex, got %q", completionStrings(completions))
+        }
+
+        completions, err = Complete("set interfaces ethernet eth0 duplex ", configModel)
+        if err != nil {
+                t.Fatalf("Complete returned error: %v", err)
+        }
+        if got := completionWords(completions, CompletionValue); !slices.Equal(got, []string{"auto", "half", "full"}) {
+                t.Errorf("Got duplex values %q, want auto, half, and full", got)
+        }
+
+        completions, err = Complete("set interfaces ethernet eth0 duplex h", configModel)
+        if err != nil {
+                t.Fatalf("Complete returned error: %v", err)
+        }
+        if got := completionWords(completions, CompletionValue); !slices.Equal(got, []string{"half"}) {
+                t.Errorf("Got duplex values %q, want half", got)
+        }
+
+        completions, err = Complete("set interfaces ethernet eth0 mtu ", configModel)
+        if err != nil {
+                t.Fatalf("Complete returned error: %v", err)
+        }
+        if got := completionWords(completions, CompletionFormat); len(got) == 0 {
+                t.Errorf("Expected a value format for mtu, got %q", completionStrings(completions))
+        }
+}
+
+func TestCompleteAST(t *testing.T) {
+        configModel := getConfigModel(t)
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 duplex 'full'
+set interfaces ethernet eth1
+set service lldp interface eth1 disable
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        // VyOS's completion lists and `<completionHelp><path>` can add
+        // more values, but the existing entries are always there.
+        tests := []struct {
+                line string
+                want []string
+        }{
+                {"set interfaces ethernet ", []string{"eth0", "eth1"}},
+                {"set interfaces ethernet eth1", []string{"eth1"}},
+                {"set service lldp interface ", []string{"eth1"}},
+        }
+        for _, test := range tests {
+                completions, err := ast.Complete(test.line)
+                if err != nil {
+                        t.Errorf("Complete(%q) returned error: %v", test.line, err)
+                        continue
+                }
+                got := completionWords(completions, CompletionValue)
+                for _, want := range test.want {
+                        if !slices.Contains(got, want) {
+                                t.Errorf("Complete(%q) = %q, want %q", test.line, got, want)
+                        }
+                }
+        }
+}
+
+func TestCompleteASTPaths(t *testing.T) {
+        configModel := getCompleteTestModel(t)
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 duplex 'full'
+set interfaces ethernet eth1
+set service lldp interface eth1 disable
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        tests := []struct {
+                line string
+                want []string
+        }{
+                {"set interfaces ethernet ", []string{
+                        "value eth0: ",
+                        "value eth1: ",
+                        "format ethN: Ethernet interface name",
+                }},
+                {"set interfaces ethernet eth1", []string{"value eth1: ", "format ethN: Ethernet interface name"}},
+                {"set service lldp interface ", []string{"value all: ", "value eth1: ", "value eth0: "}},
+        }
+        for _, test := range tests {
+                completions, err := ast.Complete(test.line)
+                if err != nil {
+                        t.Errorf("Complete(%q) returned error: %v", test.line, err)
+                        continue
+                }
+                got := completionStrings(completions)
+                if fmt.Sprint(got) != fmt.Sprint(test.want) {
+                        t.Errorf("Complete(%q) = %q, want %q", test.line, got, test.want)
+                }
+        }
+}
+
+func TestCompleteErrors(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        _, err := Complete("set interfaces ethernet eth0 dpulex ", configModel)
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Expected a ParseError, got %v", err)
+        }
+        if pe.Token != "dpulex" || pe.Column != 30 {
+                t.Errorf("Error has token %q at column %d, want %q at 30", pe.Token, pe.Column, "dpulex")
+        }
+        if len(pe.Suggestions) == 0 || pe.Suggestions[0] != "duplex" {
+                t.Errorf("Suggestions are %q, want duplex first", pe.Suggestions)
+        }
+
+        if _, err := Complete("set interfaces ethernet eth0 mtu 1500 extra ", configModel); err == nil {
+                t.Errorf("Expected an error for a word after a leaf value")
+        }
+}
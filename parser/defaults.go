@@ -0,0 +1,108 @@
+package parser
+
+import (
+        "slices"
+        "strings"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// WithDefaults returns a copy of the AST with default values filled
+// in, like VyOS's `get_config_dict(with_defaults=True)`.  A LeafNode
+// with a default value is added wherever its parent is in the config
+// but it isn't; defaults underneath nodes that aren't in the config
+// are left out, so enabling a service is still up to the config.
+func (vca *VyOSConfigAST) WithDefaults() *VyOSConfigAST {
+        c := vca.Copy()
+        addDefaults(c.Child, c.ConfigModel)
+        return c
+}
+
+// StripDefaults returns a copy of the AST without any LeafNodes that
+// just restate their default values.  A multi-value LeafNode is only
+// removed if its values are exactly the defaults.  Leaves with
+// comments are kept, so that the comments aren't lost.
+func (vca *VyOSConfigAST) StripDefaults() *VyOSConfigAST {
+        c := vca.Copy()
+        stripDefaults(c.Child)
+        return c
+}
+
+// defaultValues returns the default values for `configNode`, or nil
+// if it doesn't have a default.
+func defaultValues(configNode *configmodel.VyOSConfigNode) []string {
+        if configNode.Type != "leafnode" || !configNode.HasValue || configNode.DefaultValue == "" {
+                return nil
+        }
+        if configNode.Multi {
+                return strings.Fields(configNode.DefaultValue)
+        }
+        return []string{configNode.DefaultValue}
+}
+
+// addDefaults adds default LeafNodes to `n` and everything underneath
+// it.  `configNode` is the config model for `n`.
+func addDefaults(n *Node, configNode *configmodel.VyOSConfigNode) {
+        if configNode == nil {
+                return
+        }
+
+        for _, child := range configNode.Children {
+                values := defaultValues(child)
+                if values == nil || findChild(n, child, nil, false) != nil {
+                        continue
+                }
+                for _, value := range values {
+                        n.addNode(child, &value)
+                }
+        }
+
+        for _, child := range n.Children {
+                if child.Type != "leafnode" {
+                        addDefaults(child, child.ContextNode)
+                }
+        }
+}
+
+// stripDefaults removes default LeafNodes from `n` and everything
+// underneath it.
+func stripDefaults(n *Node) {
+        // Collect the values of each leaf with a default, so that
+        // multi-value leaves can be compared as a whole.
+        values := map[string][]string{}
+        commented := map[string]bool{}
+        for _, child := range n.Children {
+                if child.Type != "leafnode" || child.Value == nil || defaultValues(child.ContextNode) == nil {
+                        continue
+                }
+                name := child.ContextNode.Name
+                values[name] = append(values[name], *child.Value)
+                if child.Comment != "" {
+                        commented[name] = true
+                }
+        }
+
+        children := []*Node{}
+        for _, child := range n.Children {
+                if child.Type == "leafnode" {
+                        name := child.ContextNode.Name
+                        if vs, ok := values[name]; ok && !commented[name] && sameValues(vs, defaultValues(child.ContextNode)) {
+                                continue
+                        }
+                } else {
+                        stripDefaults(child)
+                }
+                children = append(children, child)
+        }
+        n.Children = children
+}
+
+// sameValues returns true if `a` and `b` hold the same values,
+// ignoring order.
+func sameValues(a, b []string) bool {
+        a = slices.Clone(a)
+        b = slices.Clone(b)
+        slices.Sort(a)
+        slices.Sort(b)
+        return slices.Equal(a, b)
+}
@@ -0,0 +1,276 @@
+package parser
+
+import (
+        "encoding/xml"
+        "fmt"
+        "strings"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// defaultsTestXML is a trimmed-down interface definition with a few
+// default values.
+const defaultsTestXML = `<?xml version="1.0"?>
+<interfaceDefinition>
+  <node name="interfaces">
+    <children>
+      <tagNode name="ethernet">
+        <children>
+          <leafNode name="address">
+            <properties>
+              <multi/>
+            </properties>
+          </leafNode>
+          <leafNode name="duplex">
+            <properties>
+              <defaultValue>auto</defaultValue>
+            </properties>
+          </leafNode>
+          <leafNode name="mtu">
+            <properties>
+              <defaultValue>1500</defaultValue>
+            </properties>
+          </leafNode>
+          <node name="ip">
+            <children>
+              <leafNode name="arp-cache-timeout">
+                <properties>
+                  <defaultValue>30</defaultValue>
+                </properties>
+              </leafNode>
+            </children>
+          </node>
+        </children>
+      </tagNode>
+    </children>
+  </node>
+  <node name="service">
+    <children>
+      <node name="ntp">
+        <children>
+          <node name="allow-client">
+            <children>
+              <leafNode name="address">
+                <properties>
+                  <multi/>
+                  <defaultValue>127.0.0.0/8 ::1/128</defaultValue>
+                </properties>
+              </leafNode>
+            </children>
+          </node>
+          <tagNode name="server"/>
+        </children>
+      </node>
+      <node name="ssh">
+        <children>
+          <leafNode name="port">
+            <properties>
+              <defaultValue>22</defaultValue>
+            </properties>
+          </leafNode>
+        </children>
+      </node>
+    </children>
+  </node>
+</interfaceDefinition>
+`
+
+// getDefaultsTestModel returns the config model from defaultsTestXML.
+func getDefaultsTestModel(t *testing.T) *configmodel.VyOSConfigNode {
+        id := &configmodel.InterfaceDefinition{}
+        if err := xml.Unmarshal([]byte(defaultsTestXML), id); err != nil {
+                t.Fatalf("Failed to parse XML: %v", err)
+        }
+        return id.VyOSConfig()
+}
+
+// getDefaultNode returns the node at `path` in the default config
+// model, skipping the test if the embedded model was generated before
+// default values were added to it.
+func getDefaultNode(t *testing.T, configModel *configmodel.VyOSConfigNode, path ...string) *configmodel.VyOSConfigNode {
+        n := getConfigNode(t, configModel, path...)
+        if n.DefaultValue == "" {
+                t.Skipf("Embedded config model has no default value for %v; regenerate syntax/*.json.gz", path)
+        }
+        return n
+}
+
+func TestDefaultValueModel(t *testing.T) {
+        configModel := getDefaultsTestModel(t)
+        ethernet := getConfigNode(t, configModel, "interfaces", "ethernet")
+        if mtu := ethernet.FindNodeByName("mtu"); mtu.DefaultValue != "1500" {
+                t.Errorf("Got mtu default %q, want %q", mtu.DefaultValue, "1500")
+        }
+        if address := ethernet.FindNodeByName("address"); address.DefaultValue != "" {
+                t.Errorf("Got address default %q, want none", address.DefaultValue)
+        }
+        address := getConfigNode(t, configModel, "service", "ntp", "allow-client", "address")
+        if !address.Multi || address.DefaultValue != "127.0.0.0/8 ::1/128" {
+                t.Errorf("Unexpected allow-client address: %+v", address)
+        }
+}
+
+func TestWithDefaults(t *testing.T) {
+        configModel := getDefaultsTestModel(t)
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 address '192.0.2.1/24'
+set interfaces ethernet eth0 mtu '9000'
+set interfaces ethernet eth1 ip arp-cache-timeout '60'
+set service ntp server time.example.com
+set service ssh
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        before, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+
+        got, err := WriteSetFormat(ast.WithDefaults())
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := `set interfaces ethernet eth0 address '192.0.2.1/24'
+set interfaces ethernet eth0 mtu '9000'
+set interfaces ethernet eth0 duplex 'auto'
+set interfaces ethernet eth1 ip arp-cache-timeout '60'
+set interfaces ethernet eth1 duplex 'auto'
+set interfaces ethernet eth1 mtu '1500'
+set service ntp server time.example.com
+set service ssh port '22'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected WithDefaults output:\n%s", diff)
+        }
+
+        // The original AST shouldn't change.
+        after, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        if after != before {
+                t.Errorf("WithDefaults changed the original AST to %q", after)
+        }
+}
+
+func TestStripDefaults(t *testing.T) {
+        configModel := getDefaultsTestModel(t)
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 duplex 'auto'
+set interfaces ethernet eth0 mtu '9000'
+set interfaces ethernet eth1 duplex 'full'
+set interfaces ethernet eth1 mtu '1500'
+set service ntp allow-client address '::1/128'
+set service ntp allow-client address '127.0.0.0/8'
+set service ssh port '22'
+comment service ssh port 'Keep this one'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        got, err := WriteSetFormat(ast.StripDefaults())
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := `set interfaces ethernet eth0 mtu '9000'
+set interfaces ethernet eth1 duplex 'full'
+set service ntp allow-client
+set service ssh port '22'
+comment service ssh port 'Keep this one'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected StripDefaults output:\n%s", diff)
+        }
+
+        // A multi-value leaf that isn't exactly the default stays.
+        config := "set service ntp allow-client address '127.0.0.0/8'\n"
+        ast, err = ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        got, err = WriteSetFormat(ast.StripDefaults())
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        if got != config {
+                t.Errorf("Got %q from StripDefaults, want %q", got, config)
+        }
+}
+
+func TestDefaultsRoundTrip(t *testing.T) {
+        configModel := getDefaultsTestModel(t)
+        config := `set interfaces ethernet eth0 address '192.0.2.1/24'
+set service ssh
+`
+        ast, err := ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        got, err := WriteSetFormat(ast.WithDefaults().StripDefaults())
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        if got != config {
+                t.Errorf("Got %q after adding and stripping defaults, want %q", got, config)
+        }
+}
+
+func TestWithDefaultsDefaultModel(t *testing.T) {
+        configModel := getConfigModel(t)
+        port := getDefaultNode(t, configModel, "service", "ssh", "port")
+
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 address '192.0.2.1/24'
+set service ssh
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        before, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+
+        got, err := WriteSetFormat(ast.WithDefaults())
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := fmt.Sprintf("set service ssh port '%s'\n", port.DefaultValue)
+        if !strings.Contains(got, want) {
+                t.Errorf("Expected %q in config with defaults:\n%s", want, got)
+        }
+        // Defaults aren't added under nodes that aren't in the config.
+        if strings.Contains(got, "set service ntp") {
+                t.Errorf("Unexpected ntp defaults in config with defaults:\n%s", got)
+        }
+
+        // The original AST shouldn't change.
+        after, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        if after != before {
+                edits := myers.ComputeEdits("before", before, after)
+                diff := gotextdiff.ToUnified("before", "after", before, edits)
+                t.Errorf("WithDefaults changed the original AST:\n%s", diff)
+        }
+
+        // An explicit value isn't replaced.
+        ast, err = ParseSetFormat("set service ssh port '2222'\n", configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        got, err = WriteSetFormat(ast.WithDefaults())
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        if !strings.Contains(got, "set service ssh port '2222'\n") || strings.Contains(got, want) {
+                t.Errorf("Expected only the explicit ssh port in config with defaults:\n%s", got)
+        }
+}
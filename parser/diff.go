@@ -0,0 +1,173 @@
+package parser
+
+import (
+        "slices"
+        "strings"
+)
+
+// Change describes a single difference between two config ASTs.
+type Change struct {
+        Type string         // ChangeAdd, ChangeDelete, ChangeModify, or ChangeComment
+        Path []*PathElement // The path to the changed node
+        Old  *Node          // The node in the old AST, or nil if it was added
+        New  *Node          // The node in the new AST, or nil if it was deleted
+}
+
+const (
+        ChangeAdd     = "add"     // New is a subtree that isn't in the old AST
+        ChangeDelete  = "delete"  // Old is a subtree that isn't in the new AST
+        ChangeModify  = "modify"  // A single-value LeafNode changed value
+        ChangeComment = "comment" // A node's comment changed
+)
+
+// Diff compares two config ASTs and returns the changes needed to
+// turn `from` into `to`.  Nodes are matched up using the config
+// model: plain Nodes and single-value LeafNodes by name, and TagNodes
+// and multi-value LeafNodes by name and value, so multi-value
+// LeafNodes are compared as sets.  Added and deleted subtrees are
+// reported once, at the top of the subtree.  For ChangeModify, the
+// final element of Path holds the new value.
+func Diff(from, to *VyOSConfigAST) []*Change {
+        return diffNodes(from.Child, to.Child, []*PathElement{})
+}
+
+// DiffCommands returns a script of `delete`, `set`, and `comment`
+// commands that turns `from` into `to` when pasted into VyOS (or
+// passed to ApplySetCommands).  Deletes come first, so that they
+// can't undo anything that the `set` commands add.
+func DiffCommands(from, to *VyOSConfigAST) []*SetCommand {
+        return ChangeCommands(Diff(from, to))
+}
+
+// ChangeCommands turns a list of changes from Diff into `delete`,
+// `set`, and `comment` commands.
+func ChangeCommands(changes []*Change) []*SetCommand {
+        commands := []*SetCommand{}
+        for _, change := range changes {
+                switch change.Type {
+                case ChangeAdd:
+                        commands = append(commands, subtreeCommands(change.Path, change.New)...)
+                case ChangeDelete:
+                        commands = append(commands, &SetCommand{Op: SetOpDelete, Path: change.Path})
+                case ChangeModify:
+                        commands = append(commands, &SetCommand{Op: SetOpSet, Path: change.Path})
+                case ChangeComment:
+                        commands = append(commands, commentCommand(change.Path, change.New.Comment))
+                }
+        }
+
+        // Put deletes first and comments last, keeping everything
+        // else in order.
+        order := map[string]int{SetOpDelete: 0, SetOpSet: 1, SetOpComment: 2}
+        slices.SortStableFunc(commands, func(a, b *SetCommand) int {
+                return order[a.Op] - order[b.Op]
+        })
+        return commands
+}
+
+// WriteSetCommands returns a list of commands in `set` format, one
+// per line.
+func WriteSetCommands(commands []*SetCommand) string {
+        lines := []string{}
+        for _, command := range commands {
+                lines = append(lines, command.String())
+        }
+        return strings.Join(lines, "\n") + "\n"
+}
+
+// diffKey returns the key used to match up children of the same node
+// in the two ASTs.
+func diffKey(n *Node) string {
+        if n.ContextNode == nil {
+                return ""
+        }
+        if n.Value != nil && (n.Type == "tagnode" || n.ContextNode.Multi) {
+                return n.ContextNode.Name + "\x00" + *n.Value
+        }
+        return n.ContextNode.Name
+}
+
+// pathTo returns `path` extended with `n`.
+func pathTo(path []*PathElement, n *Node) []*PathElement {
+        return append(slices.Clone(path), &PathElement{ContextNode: n.ContextNode, Value: n.Value})
+}
+
+// diffNodes compares the children of two matching nodes.
+func diffNodes(from, to *Node, path []*PathElement) []*Change {
+        changes := []*Change{}
+
+        toChildren := map[string]*Node{}
+        for _, child := range to.Children {
+                toChildren[diffKey(child)] = child
+        }
+        fromChildren := map[string]*Node{}
+        for _, child := range from.Children {
+                fromChildren[diffKey(child)] = child
+        }
+
+        for _, fromChild := range from.Children {
+                toChild := toChildren[diffKey(fromChild)]
+                switch {
+                case toChild == nil:
+                        changes = append(changes, &Change{Type: ChangeDelete, Path: pathTo(path, fromChild), Old: fromChild})
+                        continue
+                case fromChild.Type == "leafnode":
+                        if !equalValues(fromChild.Value, toChild.Value) {
+                                changes = append(changes, &Change{Type: ChangeModify, Path: pathTo(path, toChild), Old: fromChild, New: toChild})
+                        }
+                case fromChild.Type == "node" && len(toChild.Children) == 0 && len(fromChild.Children) > 0:
+                        // Deleting every child of a Node deletes the
+                        // Node too, so it has to be re-added.
+                        changes = append(changes,
+                                &Change{Type: ChangeDelete, Path: pathTo(path, fromChild), Old: fromChild},
+                                &Change{Type: ChangeAdd, Path: pathTo(path, toChild), New: toChild})
+                        continue
+                default:
+                        changes = append(changes, diffNodes(fromChild, toChild, pathTo(path, toChild))...)
+                }
+                if fromChild.Comment != toChild.Comment {
+                        changes = append(changes, &Change{Type: ChangeComment, Path: pathTo(path, toChild), Old: fromChild, New: toChild})
+                }
+        }
+
+        for _, toChild := range to.Children {
+                if fromChildren[diffKey(toChild)] == nil {
+                        changes = append(changes, &Change{Type: ChangeAdd, Path: pathTo(path, toChild), New: toChild})
+                }
+        }
+        return changes
+}
+
+// equalValues returns true if two (possibly nil) values are the same.
+func equalValues(a, b *string) bool {
+        if a == nil || b == nil {
+                return a == b
+        }
+        return *a == *b
+}
+
+// subtreeCommands returns the `set` and `comment` commands that
+// create `n` (found at `path`) and everything underneath it.
+func subtreeCommands(path []*PathElement, n *Node) []*SetCommand {
+        commands := []*SetCommand{}
+        for _, child := range n.Children {
+                commands = append(commands, subtreeCommands(pathTo(path, child), child)...)
+        }
+        if len(n.Children) == 0 {
+                commands = append(commands, &SetCommand{Op: SetOpSet, Path: path})
+        }
+        if n.Comment != "" {
+                commands = append(commands, commentCommand(path, n.Comment))
+        }
+        return commands
+}
+
+// commentCommand returns a `comment` command for the node at `path`.
+// Like WriteSetFormat, it leaves LeafNode values out of the path.
+func commentCommand(path []*PathElement, comment string) *SetCommand {
+        last := path[len(path)-1]
+        if last.ContextNode.Type == "leafnode" && !last.ContextNode.Multi {
+                path = append(slices.Clone(path[:len(path)-1]), &PathElement{ContextNode: last.ContextNode})
+        }
+        return &SetCommand{Op: SetOpComment, Path: path, Comment: comment}
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+        "os"
+        "strings"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+func TestDiffCommands(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        from, err := ParseSetFormat(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth0 description 'old'
+set interfaces ethernet eth1 address 'dhcp'
+set interfaces ethernet eth2 offload gro
+set system host-name 'router'
+set system name-server '8.8.8.8'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse from config: %v", err)
+        }
+
+        to, err := ParseSetFormat(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.3/24'
+set interfaces ethernet eth0 description 'new'
+set interfaces ethernet eth2
+set interfaces ethernet eth3 address 'dhcp'
+set interfaces ethernet eth3 hw-id '00:11:22:33:44:55'
+set system host-name 'router'
+set system name-server '8.8.8.8'
+comment system host-name 'Do not change'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse to config: %v", err)
+        }
+
+        got := WriteSetCommands(DiffCommands(from, to))
+        want := `delete interfaces ethernet eth0 address '10.0.0.2/24'
+delete interfaces ethernet eth1
+delete interfaces ethernet eth2 offload
+set interfaces ethernet eth0 description 'new'
+set interfaces ethernet eth0 address '10.0.0.3/24'
+set interfaces ethernet eth3 address 'dhcp'
+set interfaces ethernet eth3 hw-id '00:11:22:33:44:55'
+comment system host-name 'Do not change'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected diff commands:\n%s", diff)
+        }
+
+        changes := Diff(from, to)
+        if len(changes) != 7 {
+                t.Fatalf("Got %d changes, want 7", len(changes))
+        }
+        if c := changes[1]; c.Type != ChangeModify || *c.Old.Value != "old" || *c.New.Value != "new" {
+                t.Errorf("Unexpected change %+v", c)
+        }
+}
+
+func TestDiffApply(t *testing.T) {
+        configModel := getConfigModel(t)
+        b, err := os.ReadFile("testdata/config.set.1")
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        fromConfig := string(b)
+
+        // Make a handful of edits of different types.
+        toConfig := strings.NewReplacer(
+                "set firewall ipv4 forward filter rule 950 action 'accept'\n", "set firewall ipv4 forward filter rule 950 action 'drop'\n",
+                "set firewall ipv4 forward filter rule 999 action 'reject'\n", "",
+                "set service ntp server 2.pool.ntp.org\n", "set service ntp server 3.pool.ntp.org\n",
+                "set system name-server '8.8.4.4'\n", "set system name-server '1.1.1.1'\n",
+                "set system conntrack\n", "",
+                "set service lldp snmp\n", "set service lldp snmp\nset service lldp interface eth0\n",
+        ).Replace(fromConfig)
+
+        from, err := ParseSetFormat(fromConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse from config: %v", err)
+        }
+        to, err := ParseSetFormat(toConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse to config: %v", err)
+        }
+
+        commands := DiffCommands(from, to)
+        if len(commands) != 8 {
+                t.Errorf("Got %d commands, want 8:\n%s", len(commands), WriteSetCommands(commands))
+        }
+
+        // Applying the commands to `from` should give us `to`.
+        script := WriteSetCommands(commands)
+        parsed, err := ParseSetCommands(script, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse generated commands: %v\n%s", err, script)
+        }
+        if err := from.ApplySetCommands(parsed); err != nil {
+                t.Fatalf("Failed to apply generated commands: %v\n%s", err, script)
+        }
+        from.Sort()
+        to.Sort()
+        got, _ := WriteSetFormat(from)
+        want, _ := WriteSetFormat(to)
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Applying diff didn't produce the target config:\n%s", diff)
+        }
+
+        if changes := Diff(to, to); len(changes) != 0 {
+                t.Errorf("Expected no changes between identical configs, got %d", len(changes))
+        }
+}
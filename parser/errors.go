@@ -0,0 +1,137 @@
+package parser
+
+import (
+        "fmt"
+        "slices"
+        "strings"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// ParseError describes a problem found while parsing a config.  All
+// of the parsers return a *ParseError for problems with the config
+// itself, so callers can use errors.As to get at the details instead
+// of scraping the error string.
+type ParseError struct {
+        Filename    string   // The file being parsed, if known
+        Line        int      // 1-based line number, or 0 if unknown
+        Column      int      // 1-based column number, or 0 if unknown
+        Path        []string // The config path reached before the error
+        Token       string   // The word that caused the error, if any
+        Allowed     []string // The words that the config model allows at this point
+        Suggestions []string // Entries from Allowed that are close to Token, best first
+        Message     string   // A description of the problem
+}
+
+func (pe *ParseError) Error() string {
+        location := ""
+        if pe.Line > 0 {
+                location = fmt.Sprintf("line %d", pe.Line)
+                if pe.Column > 0 {
+                        location = fmt.Sprintf("%s, column %d", location, pe.Column)
+                }
+        }
+        if pe.Filename != "" {
+                location = strings.TrimSpace(pe.Filename + " " + location)
+        }
+
+        s := pe.Message
+        if location != "" {
+                s = fmt.Sprintf("%s at %s", s, location)
+        }
+        if len(pe.Path) > 0 {
+                s = fmt.Sprintf("%s (under %q)", s, strings.Join(pe.Path, " "))
+        }
+        if len(pe.Suggestions) > 0 {
+                s = fmt.Sprintf("%s; did you mean %s?", s, strings.Join(pe.Suggestions, " or "))
+        }
+        return s
+}
+
+// newParseError returns a ParseError with a copy of `path`, so that
+// callers are free to keep appending to their own path slice.
+func newParseError(lineno, column int, path []string, message string) *ParseError {
+        return &ParseError{
+                Line:    lineno,
+                Column:  column,
+                Path:    slices.Clone(path),
+                Message: message,
+        }
+}
+
+// newUnknownWordError returns a ParseError for a word that doesn't
+// match any of the children of `configNode`, with a list of allowed
+// words and suggestions for what the user might have meant.
+func newUnknownWordError(lineno, column int, path []string, token string, configNode *configmodel.VyOSConfigNode) *ParseError {
+        pe := newParseError(lineno, column, path, fmt.Sprintf("Unexpected word %q", token))
+        pe.Token = token
+        for _, n := range configNode.Children {
+                pe.Allowed = append(pe.Allowed, n.Name)
+        }
+        pe.Suggestions = suggest(token, pe.Allowed)
+        return pe
+}
+
+// newUnquoteError returns a ParseError for a quoted value that
+// couldn't be unquoted.
+func newUnquoteError(lineno, column int, path []string, value string, err error) *ParseError {
+        pe := newParseError(lineno, column, path, fmt.Sprintf("Couldn't unquote value: %v", err))
+        pe.Token = value
+        return pe
+}
+
+// maxSuggestions is the most "did you mean" suggestions that
+// ParseError will carry.
+const maxSuggestions = 3
+
+// suggest returns the entries from `options` that are close to
+// `token`, ranked by edit distance.  Options that start with `token`
+// are always included, so abbreviations like `eth` find `ethernet`.
+func suggest(token string, options []string) []string {
+        type candidate struct {
+                name     string
+                distance int
+        }
+
+        limit := max(2, len(token)/3)
+        candidates := []candidate{}
+        for _, option := range options {
+                distance := editDistance(token, option)
+                if distance <= limit || (len(token) > 1 && strings.HasPrefix(option, token)) {
+                        candidates = append(candidates, candidate{option, distance})
+                }
+        }
+
+        slices.SortStableFunc(candidates, func(a, b candidate) int {
+                return a.distance - b.distance
+        })
+
+        suggestions := []string{}
+        for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+                suggestions = append(suggestions, candidates[i].name)
+        }
+        return suggestions
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+        ra, rb := []rune(a), []rune(b)
+        prev := make([]int, len(rb)+1)
+        cur := make([]int, len(rb)+1)
+
+        for j := range prev {
+                prev[j] = j
+        }
+        for i := 1; i <= len(ra); i++ {
+                cur[0] = i
+                for j := 1; j <= len(rb); j++ {
+                        cost := 1
+                        if ra[i-1] == rb[j-1] {
+                                cost = 0
+                        }
+                        cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+                }
+                prev, cur = cur, prev
+        }
+        return prev[len(rb)]
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+        "errors"
+        "slices"
+        "testing"
+)
+
+func TestParseErrorSet(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `set system host-name 'router1'
+set interfaces ethernet eth0 adress 'dhcp'
+`
+        _, err := ParseSetFormat(config, configModel)
+        if err == nil {
+                t.Fatalf("Expected error from invalid config")
+        }
+
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error of type %T, want *ParseError", err)
+        }
+
+        if pe.Line != 2 {
+                t.Errorf("Got Line=%d, want 2", pe.Line)
+        }
+        if pe.Column != 30 {
+                t.Errorf("Got Column=%d, want 30", pe.Column)
+        }
+        if pe.Token != "adress" {
+                t.Errorf("Got Token=%q, want %q", pe.Token, "adress")
+        }
+        wantPath := []string{"interfaces", "ethernet", "eth0"}
+        if !slices.Equal(pe.Path, wantPath) {
+                t.Errorf("Got Path=%v, want %v", pe.Path, wantPath)
+        }
+        if !slices.Contains(pe.Allowed, "address") || !slices.Contains(pe.Allowed, "mtu") {
+                t.Errorf("Allowed list %v is missing expected entries", pe.Allowed)
+        }
+        if len(pe.Suggestions) == 0 || pe.Suggestions[0] != "address" {
+                t.Errorf("Got Suggestions=%v, want address first", pe.Suggestions)
+        }
+}
+
+func TestParseErrorSetMissingValue(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        _, err := ParseSetFormat(`set interfaces ethernet`, configModel)
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error %v, want *ParseError", err)
+        }
+        if pe.Column != 16 {
+                t.Errorf("Got Column=%d, want 16", pe.Column)
+        }
+
+        _, err = ParseSetFormat(`sett system host-name 'router1'`, configModel)
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error %v, want *ParseError", err)
+        }
+        if len(pe.Suggestions) == 0 || pe.Suggestions[0] != "set" {
+                t.Errorf("Got Suggestions=%v, want set first", pe.Suggestions)
+        }
+}
+
+func TestParseErrorShow(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `interfaces {
+    ethernet eth0 {
+        address 10.0.0.1/24
+    }
+}
+protocols {
+    bgp {
+        neighbour 10.0.0.2 {
+        }
+    }
+}
+`
+        for name, parse := range map[string]func(string) error{
+                "show": func(c string) error {
+                        _, err := ParseShowFormat(c, configModel)
+                        return err
+                },
+                "config.boot": func(c string) error {
+                        _, err := ParseConfigBootFormat(c, configModel)
+                        return err
+                },
+        } {
+                err := parse(config)
+                var pe *ParseError
+                if !errors.As(err, &pe) {
+                        t.Errorf("%s: got error %v, want *ParseError", name, err)
+                        continue
+                }
+                if pe.Line != 8 || pe.Column != 9 {
+                        t.Errorf("%s: got line %d column %d, want line 8 column 9", name, pe.Line, pe.Column)
+                }
+                wantPath := []string{"protocols", "bgp"}
+                if !slices.Equal(pe.Path, wantPath) {
+                        t.Errorf("%s: got Path=%v, want %v", name, pe.Path, wantPath)
+                }
+                if len(pe.Suggestions) == 0 || pe.Suggestions[0] != "neighbor" {
+                        t.Errorf("%s: got Suggestions=%v, want neighbor first", name, pe.Suggestions)
+                }
+        }
+}
+
+func TestEditDistance(t *testing.T) {
+        tests := []struct {
+                a, b string
+                want int
+        }{
+                {"", "", 0},
+                {"abc", "", 3},
+                {"adress", "address", 1},
+                {"kitten", "sitting", 3},
+                {"ethernet", "ethernet", 0},
+        }
+
+        for _, test := range tests {
+                got := editDistance(test.a, test.b)
+                if got != test.want {
+                        t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+                }
+        }
+}
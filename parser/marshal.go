@@ -0,0 +1,542 @@
+package parser
+
+import (
+        "encoding"
+        "fmt"
+        "reflect"
+        "slices"
+        "strconv"
+        "strings"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// Unmarshal and Marshal convert between config ASTs and user-defined
+// Go structs, in the style of encoding/json.  Struct fields are
+// mapped to the config using `vyos` tags, which hold a path relative
+// to the struct's own place in the config, with words separated by
+// `/`:
+//
+//      type Ethernet struct {
+//              Address     []string `vyos:"address"`
+//              Description string   `vyos:"description"`
+//              MTU         int      `vyos:"mtu"`
+//              NoFlowCtl   bool     `vyos:"disable-flow-control"`
+//      }
+//
+//      type Config struct {
+//              HostName  string              `vyos:"system/host-name"`
+//              Ethernets map[string]Ethernet `vyos:"interfaces/ethernet"`
+//              Eth0MTU   *int                `vyos:"interfaces/ethernet/eth0/mtu"`
+//      }
+//
+// The config model decides how each field is mapped:
+//
+//   - TagNodes map to map[string]T, keyed by the tag value.  A path
+//     can also include a specific tag value, like `ethernet/eth0`.
+//   - Multi LeafNodes map to slices.
+//   - Valueless LeafNodes map to bool or *bool.
+//   - LeafNodes with values map to strings, numbers, bools, or any
+//     type that implements encoding.TextUnmarshaler and
+//     encoding.TextMarshaler.
+//   - Nodes (and tag entries) map to structs, or to bool or *bool
+//     to just check for their presence.
+//
+// Pointers to any of these are allowed, and are left nil when the
+// config doesn't have the field; a *bool is set to true when the
+// node is present.  When marshaling, a nil or false *bool leaves the
+// node out.  Fields without a `vyos` tag (or
+// with `vyos:"-"`) are ignored.  Every tag path is checked against
+// the config model, even when the config doesn't contain it, so
+// typos in tags are always reported.  When marshaling, zero values
+// are omitted; use a pointer if a zero needs to appear in the
+// config.
+
+var (
+        textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+        textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Unmarshal copies values from `ast` into `v`, which must be a
+// non-nil pointer to a struct.  Fields whose config paths aren't in
+// the AST are left unchanged.
+func Unmarshal(ast *VyOSConfigAST, v any) error {
+        rv := reflect.ValueOf(v)
+        if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+                return fmt.Errorf("Unmarshal requires a non-nil pointer to a struct, not %T", v)
+        }
+        if ast.ConfigModel == nil {
+                return fmt.Errorf("Unmarshal requires an AST with a ConfigModel")
+        }
+        return unmarshalStruct(ast.Child, ast.ConfigModel, rv.Elem())
+}
+
+// Marshal builds a new config AST from `v`, which must be a struct
+// or a pointer to a struct.
+func Marshal(v any, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, error) {
+        rv := reflect.ValueOf(v)
+        for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+                rv = rv.Elem()
+        }
+        if rv.Kind() != reflect.Struct {
+                return nil, fmt.Errorf("Marshal requires a struct or a pointer to a struct, not %T", v)
+        }
+
+        ast := &VyOSConfigAST{
+                Child:       &Node{Type: "root"},
+                ConfigModel: configModel,
+        }
+        if err := marshalStruct(ast.Child, configModel, rv); err != nil {
+                return nil, err
+        }
+        return ast, nil
+}
+
+// fieldTarget is where a tagged struct field points in the config
+// model, and (when unmarshaling) in the AST.
+type fieldTarget struct {
+        configNode *configmodel.VyOSConfigNode
+        tagValues  bool    // The path ends at a TagNode without a value
+        parent     *Node   // The AST node holding the target, or nil
+        nodes      []*Node // Matching AST nodes under parent
+}
+
+// structFields returns the tagged fields of a struct type, along
+// with their tag paths.
+func structFields(t reflect.Type) ([]reflect.StructField, [][]string) {
+        fields := []reflect.StructField{}
+        paths := [][]string{}
+        for i := 0; i < t.NumField(); i++ {
+                f := t.Field(i)
+                tag, ok := f.Tag.Lookup("vyos")
+                if !ok || tag == "-" || !f.IsExported() {
+                        continue
+                }
+                fields = append(fields, f)
+                paths = append(paths, strings.Split(tag, "/"))
+        }
+        return fields, paths
+}
+
+// resolveField walks a tag path through the config model, and
+// through the AST starting at `node` if it's non-nil.  If `create` is
+// true, then missing AST nodes along the way are created.
+func resolveField(node *Node, configNode *configmodel.VyOSConfigNode, path []string, create bool) (*fieldTarget, error) {
+        target := &fieldTarget{configNode: configNode, parent: node}
+        current := node
+        pendingTag := false
+
+        for i, word := range path {
+                if pendingTag {
+                        // This word is the value of the preceding TagNode
+                        pendingTag = false
+                        current = findChild(target.parent, target.configNode, &word, create)
+                        continue
+                }
+
+                if target.configNode.Type == "leafnode" {
+                        return nil, fmt.Errorf("Path %q continues past LeafNode %q", strings.Join(path, "/"), target.configNode.Name)
+                }
+                newConfigNode := target.configNode.FindNodeByName(word)
+                if newConfigNode == nil {
+                        return nil, newUnknownWordError(0, 0, path[:i], word, target.configNode)
+                }
+
+                target.configNode = newConfigNode
+                target.parent = current
+                if newConfigNode.Type == "tagnode" {
+                        pendingTag = true
+                        current = nil
+                } else if newConfigNode.Type == "leafnode" {
+                        current = nil
+                } else {
+                        current = findChild(target.parent, newConfigNode, nil, create)
+                }
+        }
+
+        target.tagValues = pendingTag
+        if target.parent != nil && (pendingTag || target.configNode.Type == "leafnode") {
+                for _, child := range target.parent.Children {
+                        if child.ContextNode != nil && child.ContextNode.Name == target.configNode.Name {
+                                target.nodes = append(target.nodes, child)
+                        }
+                }
+        } else if current != nil {
+                target.nodes = []*Node{current}
+        }
+        return target, nil
+}
+
+// findChild returns the child of `node` that matches `configNode`
+// and `value`, creating it if `create` is true.
+func findChild(node *Node, configNode *configmodel.VyOSConfigNode, value *string, create bool) *Node {
+        if node == nil {
+                return nil
+        }
+        pe := &PathElement{ContextNode: configNode, Value: value}
+        for _, child := range node.Children {
+                if child.matches(pe) {
+                        return child
+                }
+        }
+        if create {
+                return node.addNode(configNode, value)
+        }
+        return nil
+}
+
+// unmarshalStruct fills in the tagged fields of `sv` from the
+// children of `node`.  If `node` is nil, then the tags are still
+// checked against the config model, but nothing is set.
+func unmarshalStruct(node *Node, configNode *configmodel.VyOSConfigNode, sv reflect.Value) error {
+        fields, paths := structFields(sv.Type())
+        for i, f := range fields {
+                target, err := resolveField(node, configNode, paths[i], false)
+                if err != nil {
+                        return fieldError(sv.Type(), f, err)
+                }
+                if err := unmarshalField(target, sv.Field(i)); err != nil {
+                        return fieldError(sv.Type(), f, err)
+                }
+        }
+        return nil
+}
+
+// fieldError adds the name of a struct field to `err`.
+func fieldError(t reflect.Type, f reflect.StructField, err error) error {
+        if t.Name() == "" {
+                return fmt.Errorf("Field %s: %w", f.Name, err)
+        }
+        return fmt.Errorf("Field %s.%s: %w", t.Name(), f.Name, err)
+}
+
+// unmarshalField sets a single field from the AST nodes in `target`.
+func unmarshalField(target *fieldTarget, fv reflect.Value) error {
+        cn := target.configNode
+
+        switch {
+        case target.tagValues:
+                if fv.Kind() != reflect.Map || fv.Type().Key().Kind() != reflect.String {
+                        return fmt.Errorf("TagNode %q needs a map[string]T, not %v", cn.Name, fv.Type())
+                }
+                elemType := fv.Type().Elem()
+                if len(target.nodes) == 0 {
+                        // Still check the element type's tags.
+                        return unmarshalContainer(nil, cn, reflect.New(elemType).Elem())
+                }
+                m := reflect.MakeMap(fv.Type())
+                for _, n := range target.nodes {
+                        elem := reflect.New(elemType).Elem()
+                        if err := unmarshalContainer(n, cn, elem); err != nil {
+                                return err
+                        }
+                        m.SetMapIndex(reflect.ValueOf(*n.Value).Convert(fv.Type().Key()), elem)
+                }
+                fv.Set(m)
+                return nil
+
+        case cn.Type == "leafnode" && cn.Multi && cn.HasValue:
+                if fv.Kind() != reflect.Slice {
+                        return fmt.Errorf("Multi LeafNode %q needs a slice, not %v", cn.Name, fv.Type())
+                }
+                if len(target.nodes) == 0 {
+                        return checkScalarType(fv.Type().Elem())
+                }
+                s := reflect.MakeSlice(fv.Type(), len(target.nodes), len(target.nodes))
+                for i, n := range target.nodes {
+                        if err := setScalar(s.Index(i), *n.Value); err != nil {
+                                return fmt.Errorf("%q: %w", cn.Name, err)
+                        }
+                }
+                fv.Set(s)
+                return nil
+
+        case cn.Type == "leafnode" && !cn.HasValue:
+                if !isBoolType(fv.Type()) {
+                        return fmt.Errorf("Valueless LeafNode %q needs a bool or *bool, not %v", cn.Name, fv.Type())
+                }
+                if len(target.nodes) > 0 {
+                        setPresent(fv)
+                }
+                return nil
+
+        case cn.Type == "leafnode":
+                if len(target.nodes) == 0 {
+                        return checkScalarType(fv.Type())
+                }
+                if err := setScalar(fv, *target.nodes[0].Value); err != nil {
+                        return fmt.Errorf("%q: %w", cn.Name, err)
+                }
+                return nil
+        }
+
+        // A Node, or a TagNode with a specific value.
+        var n *Node
+        if len(target.nodes) > 0 {
+                n = target.nodes[0]
+        }
+        return unmarshalContainer(n, cn, fv)
+}
+
+// unmarshalContainer fills in a struct, pointer to struct, or bool
+// from a Node or tag entry.  `n` may be nil if the config doesn't
+// have the node.
+func unmarshalContainer(n *Node, configNode *configmodel.VyOSConfigNode, fv reflect.Value) error {
+        switch {
+        case fv.Kind() == reflect.Bool:
+                fv.SetBool(n != nil)
+                return nil
+        case isBoolType(fv.Type()):
+                if n != nil {
+                        setPresent(fv)
+                }
+                return nil
+        case fv.Kind() == reflect.Struct:
+                return unmarshalStruct(n, configNode, fv)
+        case fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct:
+                if n == nil {
+                        return unmarshalStruct(nil, configNode, reflect.New(fv.Type().Elem()).Elem())
+                }
+                p := reflect.New(fv.Type().Elem())
+                if err := unmarshalStruct(n, configNode, p.Elem()); err != nil {
+                        return err
+                }
+                fv.Set(p)
+                return nil
+        }
+        return fmt.Errorf("%s %q needs a struct, pointer to struct, bool, or *bool, not %v", configNode.Type, configNode.Name, fv.Type())
+}
+
+// isBoolType returns true for bool and *bool, the types that can
+// record whether a node is present.
+func isBoolType(t reflect.Type) bool {
+        return t.Kind() == reflect.Bool || (t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Bool)
+}
+
+// setPresent sets a bool or *bool to true.
+func setPresent(fv reflect.Value) {
+        if fv.Kind() == reflect.Pointer {
+                fv.Set(reflect.New(fv.Type().Elem()))
+                fv = fv.Elem()
+        }
+        fv.SetBool(true)
+}
+
+// isAbsent returns true if `fv` is zero, or is a *bool pointing to
+// false, so that marshaling should leave it out.
+func isAbsent(fv reflect.Value) bool {
+        if fv.IsZero() {
+                return true
+        }
+        return fv.Kind() == reflect.Pointer && fv.Elem().Kind() == reflect.Bool && !fv.Elem().Bool()
+}
+
+// checkScalarType returns an error if setScalar can't handle `t`.
+func checkScalarType(t reflect.Type) error {
+        if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+                return nil
+        }
+        switch t.Kind() {
+        case reflect.Pointer:
+                return checkScalarType(t.Elem())
+        case reflect.String, reflect.Bool,
+                reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+                reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+                reflect.Float32, reflect.Float64:
+                return nil
+        }
+        return fmt.Errorf("Can't store a LeafNode value in %v", t)
+}
+
+// setScalar parses `value` into `fv`.
+func setScalar(fv reflect.Value, value string) error {
+        if fv.Kind() == reflect.Pointer {
+                p := reflect.New(fv.Type().Elem())
+                if err := setScalar(p.Elem(), value); err != nil {
+                        return err
+                }
+                fv.Set(p)
+                return nil
+        }
+        if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+                return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+        }
+
+        switch fv.Kind() {
+        case reflect.String:
+                fv.SetString(value)
+        case reflect.Bool:
+                b, err := strconv.ParseBool(value)
+                if err != nil {
+                        return err
+                }
+                fv.SetBool(b)
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+                i, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+                if err != nil {
+                        return err
+                }
+                fv.SetInt(i)
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+                u, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+                if err != nil {
+                        return err
+                }
+                fv.SetUint(u)
+        case reflect.Float32, reflect.Float64:
+                f, err := strconv.ParseFloat(value, fv.Type().Bits())
+                if err != nil {
+                        return err
+                }
+                fv.SetFloat(f)
+        default:
+                return checkScalarType(fv.Type())
+        }
+        return nil
+}
+
+// formatScalar turns `fv` into a LeafNode value.  It returns false if
+// `fv` is a zero value and should be left out of the config.
+func formatScalar(fv reflect.Value) (string, bool, error) {
+        if fv.IsZero() {
+                return "", false, nil
+        }
+        if fv.Kind() == reflect.Pointer {
+                // Pointers always mean "present", even for zero values.
+                fv = fv.Elem()
+        }
+        s, err := formatValue(fv)
+        return s, true, err
+}
+
+// formatValue turns `fv` into a LeafNode value.
+func formatValue(fv reflect.Value) (string, error) {
+        if fv.Type().Implements(textMarshalerType) {
+                b, err := fv.Interface().(encoding.TextMarshaler).MarshalText()
+                return string(b), err
+        }
+
+        switch fv.Kind() {
+        case reflect.String:
+                return fv.String(), nil
+        case reflect.Bool:
+                return strconv.FormatBool(fv.Bool()), nil
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+                return strconv.FormatInt(fv.Int(), 10), nil
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+                return strconv.FormatUint(fv.Uint(), 10), nil
+        case reflect.Float32, reflect.Float64:
+                return strconv.FormatFloat(fv.Float(), 'f', -1, fv.Type().Bits()), nil
+        }
+        return "", checkScalarType(fv.Type())
+}
+
+// marshalStruct adds the tagged fields of `sv` underneath `node`.
+func marshalStruct(node *Node, configNode *configmodel.VyOSConfigNode, sv reflect.Value) error {
+        fields, paths := structFields(sv.Type())
+        for i, f := range fields {
+                // Check the path against the model before creating
+                // anything in the AST.
+                target, err := resolveField(nil, configNode, paths[i], false)
+                if err != nil {
+                        return fieldError(sv.Type(), f, err)
+                }
+                if isAbsent(sv.Field(i)) {
+                        if err := unmarshalField(target, reflect.New(f.Type).Elem()); err != nil {
+                                // Type doesn't match the model.
+                                return fieldError(sv.Type(), f, err)
+                        }
+                        continue
+                }
+
+                target, err = resolveField(node, configNode, paths[i], true)
+                if err != nil {
+                        return fieldError(sv.Type(), f, err)
+                }
+                if err := marshalField(target, sv.Field(i)); err != nil {
+                        return fieldError(sv.Type(), f, err)
+                }
+        }
+        return nil
+}
+
+// marshalField adds a single non-zero field to the AST at `target`.
+func marshalField(target *fieldTarget, fv reflect.Value) error {
+        cn := target.configNode
+        parent := target.parent
+
+        switch {
+        case target.tagValues:
+                if fv.Kind() != reflect.Map || fv.Type().Key().Kind() != reflect.String {
+                        return fmt.Errorf("TagNode %q needs a map[string]T, not %v", cn.Name, fv.Type())
+                }
+                keys := []string{}
+                for _, k := range fv.MapKeys() {
+                        keys = append(keys, k.String())
+                }
+                slices.Sort(keys)
+                for _, k := range keys {
+                        value := k
+                        entry := parent.addNode(cn, &value)
+                        elem := fv.MapIndex(reflect.ValueOf(k).Convert(fv.Type().Key()))
+                        if err := marshalContainer(entry, cn, elem); err != nil {
+                                return err
+                        }
+                }
+                return nil
+
+        case cn.Type == "leafnode" && cn.Multi && cn.HasValue:
+                if fv.Kind() != reflect.Slice {
+                        return fmt.Errorf("Multi LeafNode %q needs a slice, not %v", cn.Name, fv.Type())
+                }
+                for i := 0; i < fv.Len(); i++ {
+                        value, ok, err := formatScalar(fv.Index(i))
+                        if err != nil {
+                                return fmt.Errorf("%q: %w", cn.Name, err)
+                        }
+                        if ok {
+                                parent.addNode(cn, &value)
+                        }
+                }
+                return nil
+
+        case cn.Type == "leafnode" && !cn.HasValue:
+                if !isBoolType(fv.Type()) {
+                        return fmt.Errorf("Valueless LeafNode %q needs a bool or *bool, not %v", cn.Name, fv.Type())
+                }
+                parent.addNode(cn, nil)
+                return nil
+
+        case cn.Type == "leafnode":
+                value, ok, err := formatScalar(fv)
+                if err != nil {
+                        return fmt.Errorf("%q: %w", cn.Name, err)
+                }
+                if ok {
+                        parent.addNode(cn, &value)
+                }
+                return nil
+        }
+
+        // resolveField created the Node (or tag entry) for us.
+        return marshalContainer(target.nodes[0], cn, fv)
+}
+
+// marshalContainer fills in a Node or tag entry from a struct,
+// pointer to struct, or bool.
+func marshalContainer(n *Node, configNode *configmodel.VyOSConfigNode, fv reflect.Value) error {
+        switch {
+        case isBoolType(fv.Type()):
+                // Presence is all that matters.
+                return nil
+        case fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct:
+                if fv.IsNil() {
+                        // A nil map entry is an empty tag entry.
+                        return nil
+                }
+                return marshalStruct(n, configNode, fv.Elem())
+        case fv.Kind() == reflect.Struct:
+                return marshalStruct(n, configNode, fv)
+        }
+        return fmt.Errorf("%s %q needs a struct, pointer to struct, or bool, not %v", configNode.Type, configNode.Name, fv.Type())
+}
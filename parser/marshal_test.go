@@ -0,0 +1,242 @@
+package parser
+
+import (
+        "errors"
+        "net/netip"
+        "os"
+        "slices"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+type testEthernet struct {
+        Address     []string `vyos:"address"`
+        Description string   `vyos:"description"`
+        HWID        string   `vyos:"hw-id"`
+        NoFlowCtl   bool     `vyos:"disable-flow-control"`
+        RingRX      *int     `vyos:"ring-buffer/rx"`
+        Offload     *struct {
+                GRO bool `vyos:"gro"`
+                TSO bool `vyos:"tso"`
+        } `vyos:"offload"`
+}
+
+type testConfig struct {
+        HostName    string                  `vyos:"system/host-name"`
+        NameServers []netip.Addr            `vyos:"system/name-server"`
+        Ethernets   map[string]testEthernet `vyos:"interfaces/ethernet"`
+        Eth5        *testEthernet           `vyos:"interfaces/ethernet/eth5"`
+        HasLoopback bool                    `vyos:"interfaces/loopback/lo"`
+        Revisions   uint16                  `vyos:"system/config-management/commit-revisions"`
+        MDNS        bool                    `vyos:"service/mdns"`
+        Ignored     string
+        Skipped     string `vyos:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+        configModel := getConfigModel(t)
+        b, err := os.ReadFile("testdata/config.set.1")
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        ast, err := ParseSetFormat(string(b), configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        var c testConfig
+        if err := Unmarshal(ast, &c); err != nil {
+                t.Fatalf("Unmarshal failed: %v", err)
+        }
+
+        if c.HostName != "router-test" {
+                t.Errorf("HostName = %q, want %q", c.HostName, "router-test")
+        }
+        wantNS := []netip.Addr{netip.MustParseAddr("10.1.5.70"), netip.MustParseAddr("8.8.8.8"), netip.MustParseAddr("8.8.4.4")}
+        if !slices.Equal(c.NameServers, wantNS) {
+                t.Errorf("NameServers = %v, want %v", c.NameServers, wantNS)
+        }
+        if len(c.Ethernets) != 6 {
+                t.Errorf("len(Ethernets) = %d, want 6", len(c.Ethernets))
+        }
+        eth0 := c.Ethernets["eth0"]
+        if !slices.Equal(eth0.Address, []string{"10.250.1.254/24"}) || !eth0.NoFlowCtl || eth0.HWID != "6c:b3:11:88:0f:6e" {
+                t.Errorf("Unexpected eth0: %+v", eth0)
+        }
+        if eth0.RingRX == nil || *eth0.RingRX != 8192 {
+                t.Errorf("eth0 RingRX = %v, want 8192", eth0.RingRX)
+        }
+        if eth0.Offload == nil || !eth0.Offload.GRO || !eth0.Offload.TSO {
+                t.Errorf("eth0 Offload = %+v, want GRO and TSO", eth0.Offload)
+        }
+        if eth4 := c.Ethernets["eth4"]; eth4.Offload != nil || eth4.RingRX != nil || eth4.NoFlowCtl {
+                t.Errorf("Unexpected eth4: %+v", eth4)
+        }
+        if c.Eth5 == nil || !slices.Equal(c.Eth5.Address, []string{"dhcp"}) {
+                t.Errorf("Eth5 = %+v, want address dhcp", c.Eth5)
+        }
+        if !c.HasLoopback || !c.MDNS || c.Revisions != 100 {
+                t.Errorf("HasLoopback = %v, MDNS = %v, Revisions = %d", c.HasLoopback, c.MDNS, c.Revisions)
+        }
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+        configModel := getConfigModel(t)
+        ast, err := ParseSetFormat("set system host-name 'router'\nset system config-management commit-revisions 'many'\n", configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        var badPath struct {
+                HostName string `vyos:"system/hostname"`
+        }
+        err = Unmarshal(ast, &badPath)
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Expected a ParseError for a bad tag path, got %v", err)
+        }
+        if pe.Token != "hostname" || !slices.Contains(pe.Suggestions, "host-name") {
+                t.Errorf("Unexpected ParseError: %+v", pe)
+        }
+
+        // Bad paths are reported even when the config doesn't have
+        // anything under them.
+        var missing struct {
+                BGP string `vyos:"protocols/bgp/system-ass"`
+        }
+        if err := Unmarshal(ast, &missing); err == nil {
+                t.Errorf("Expected an error for a bad path under a missing node")
+        }
+
+        var wrongType struct {
+                HostName []string `vyos:"system/host-name"`
+        }
+        if err := Unmarshal(ast, &wrongType); err == nil {
+                t.Errorf("Expected an error for a slice on a single LeafNode")
+        }
+
+        var tagNotMap struct {
+                Ethernet string `vyos:"interfaces/ethernet"`
+        }
+        if err := Unmarshal(ast, &tagNotMap); err == nil {
+                t.Errorf("Expected an error for a TagNode without a map")
+        }
+
+        var badValue struct {
+                Revisions int `vyos:"system/config-management/commit-revisions"`
+        }
+        if err := Unmarshal(ast, &badValue); err == nil {
+                t.Errorf("Expected an error for a non-numeric value")
+        }
+
+        if err := Unmarshal(ast, badValue); err == nil {
+                t.Errorf("Expected an error for a non-pointer")
+        }
+}
+
+func TestMarshal(t *testing.T) {
+        configModel := getConfigModel(t)
+        rx := 0
+        c := testConfig{
+                HostName:    "router",
+                NameServers: []netip.Addr{netip.MustParseAddr("192.0.2.53"), netip.MustParseAddr("2001:db8::53")},
+                Ethernets: map[string]testEthernet{
+                        "eth1": {Address: []string{"192.0.2.1/24"}, Description: "uplink"},
+                        "eth0": {NoFlowCtl: true, RingRX: &rx},
+                },
+                HasLoopback: true,
+                Revisions:   20,
+                Ignored:     "not in the config",
+        }
+
+        ast, err := Marshal(&c, configModel)
+        if err != nil {
+                t.Fatalf("Marshal failed: %v", err)
+        }
+        got, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+
+        want := `set system host-name 'router'
+set system name-server '192.0.2.53'
+set system name-server '2001:db8::53'
+set system config-management commit-revisions '20'
+set interfaces ethernet eth0 disable-flow-control
+set interfaces ethernet eth0 ring-buffer rx '0'
+set interfaces ethernet eth1 address '192.0.2.1/24'
+set interfaces ethernet eth1 description 'uplink'
+set interfaces loopback lo
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected Marshal output:\n%s", diff)
+        }
+
+        // Round-trip back through Unmarshal.
+        var c2 testConfig
+        if err := Unmarshal(ast, &c2); err != nil {
+                t.Fatalf("Unmarshal failed: %v", err)
+        }
+        if c2.HostName != c.HostName || c2.Revisions != c.Revisions || len(c2.Ethernets) != 2 || *c2.Ethernets["eth0"].RingRX != 0 {
+                t.Errorf("Round-trip mismatch: %+v", c2)
+        }
+
+        var bad struct {
+                HostName map[string]string `vyos:"system/host-name"`
+        }
+        if _, err := Marshal(bad, configModel); err == nil {
+                t.Errorf("Expected an error for a map on a LeafNode")
+        }
+}
+
+func TestMarshalBoolPointers(t *testing.T) {
+        configModel := getConfigModel(t)
+        type flags struct {
+                NoFlowCtl   *bool `vyos:"interfaces/ethernet/eth0/disable-flow-control"`
+                Eth4NoFlow  *bool `vyos:"interfaces/ethernet/eth4/disable-flow-control"`
+                HasLoopback *bool `vyos:"interfaces/loopback/lo"`
+                Offload     *bool `vyos:"interfaces/ethernet/eth4/offload"`
+        }
+
+        b, err := os.ReadFile("testdata/config.set.1")
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        ast, err := ParseSetFormat(string(b), configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        var f flags
+        if err := Unmarshal(ast, &f); err != nil {
+                t.Fatalf("Unmarshal failed: %v", err)
+        }
+        if f.NoFlowCtl == nil || !*f.NoFlowCtl || f.HasLoopback == nil || !*f.HasLoopback {
+                t.Errorf("Expected present nodes to be true, got %+v", f)
+        }
+        if f.Eth4NoFlow != nil || f.Offload != nil {
+                t.Errorf("Expected missing nodes to be nil, got %+v", f)
+        }
+
+        yes, no := true, false
+        ast, err = Marshal(&flags{NoFlowCtl: &yes, Eth4NoFlow: &no, HasLoopback: &yes}, configModel)
+        if err != nil {
+                t.Fatalf("Marshal failed: %v", err)
+        }
+        got, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := `set interfaces ethernet eth0 disable-flow-control
+set interfaces loopback lo
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected Marshal output:\n%s", diff)
+        }
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+        "maps"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// MergeOptions controls how MergeWithOptions combines two configs.
+type MergeOptions struct {
+        // Replace makes the merge behave like VyOS's `load` command
+        // instead of `merge`: `dst` ends up with exactly the config
+        // in `src`, and anything in `dst` that isn't in `src` is
+        // removed.
+        Replace bool
+}
+
+// Override records a part of `dst` that a merge changed or removed.
+type Override struct {
+        Path []*PathElement // The path to the overridden node
+        Old  *Node          // The node in `dst` before the merge
+        New  *Node          // The node from `src`, or nil if it was removed
+}
+
+// Merge merges `src` into `dst`, the same way as VyOS's `merge`
+// command: TagNode entries and multi-value LeafNodes are combined,
+// and values from `src` replace values in `dst` for single-value
+// LeafNodes.  Comments in `src` replace comments in `dst`.
+func Merge(dst, src *VyOSConfigAST) error {
+        _, err := MergeWithOptions(dst, src, nil)
+        return err
+}
+
+// MergeWithOptions is like Merge, but takes a MergeOptions to control
+// merging.  It returns the parts of `dst` that were overridden by
+// `src`.  `options` may be nil.
+func MergeWithOptions(dst, src *VyOSConfigAST, options *MergeOptions) ([]*Override, error) {
+        if options == nil {
+                options = &MergeOptions{}
+        }
+
+        if options.Replace {
+                overrides := []*Override{}
+                for _, change := range Diff(dst, src) {
+                        switch change.Type {
+                        case ChangeDelete, ChangeModify:
+                                overrides = append(overrides, &Override{Path: change.Path, Old: change.Old, New: change.New})
+                        case ChangeComment:
+                                if change.Old.Comment != "" {
+                                        overrides = append(overrides, &Override{Path: change.Path, Old: change.Old, New: change.New})
+                                }
+                        }
+                }
+                dst.Child = src.Child.Copy()
+                dst.ComponentVersions = maps.Clone(src.ComponentVersions)
+                dst.ReleaseVersion = src.ReleaseVersion
+                return overrides, nil
+        }
+
+        overrides := []*Override{}
+        if err := mergeInto(dst.Child, src.Child, dst.ConfigModel, []*PathElement{}, &overrides); err != nil {
+                return nil, err
+        }
+        return overrides, nil
+}
+
+// mergeInto merges the children of `src` into `dst`.  If
+// `configModel` is non-nil, then new nodes use it instead of the
+// config model that `src` was parsed with, so that `dst` doesn't end
+// up with a mix of models.
+func mergeInto(dst, src *Node, configModel *configmodel.VyOSConfigNode, path []*PathElement, overrides *[]*Override) error {
+        for _, child := range src.Children {
+                contextNode := child.ContextNode
+                if configModel != nil {
+                        contextNode = configModel.FindNodeByName(child.ContextNode.Name)
+                        if contextNode == nil {
+                                return newUnknownWordError(0, 0, pathWords(path), child.ContextNode.Name, configModel)
+                        }
+                }
+
+                // addNode overwrites single-value nodes, so see what's
+                // there first.
+                var old *Node
+                if !contextNode.Multi {
+                        old = dst.findPath([]*PathElement{{ContextNode: contextNode}})
+                }
+                if old != nil && old.Type == "leafnode" && !equalValues(old.Value, child.Value) {
+                        *overrides = append(*overrides, &Override{Path: pathTo(path, old), Old: old.Copy(), New: child})
+                }
+
+                var value *string
+                if child.Value != nil {
+                        v := *child.Value
+                        value = &v
+                }
+                n := dst.addNode(contextNode, value)
+
+                if child.Comment != "" {
+                        if n.Comment != "" && n.Comment != child.Comment {
+                                *overrides = append(*overrides, &Override{Path: pathTo(path, n), Old: n.Copy(), New: child})
+                        }
+                        n.Comment = child.Comment
+                }
+
+                if err := mergeInto(n, child, contextNode, pathTo(path, n), overrides); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
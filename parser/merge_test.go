@@ -0,0 +1,128 @@
+package parser
+
+import (
+        "errors"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+const mergeBaseConfig = `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 description 'template'
+set service ssh port '22'
+set system host-name 'template'
+set system name-server '8.8.8.8'
+comment system host-name 'Set per site'
+`
+
+const mergeOverlayConfig = `set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth1 address 'dhcp'
+set system host-name 'site1'
+set system name-server '8.8.8.8'
+set system name-server '1.1.1.1'
+`
+
+func TestMerge(t *testing.T) {
+        configModel := getConfigModel(t)
+        dst, err := ParseSetFormat(mergeBaseConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse base config: %v", err)
+        }
+        src, err := ParseSetFormat(mergeOverlayConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse overlay config: %v", err)
+        }
+
+        overrides, err := MergeWithOptions(dst, src, nil)
+        if err != nil {
+                t.Fatalf("Merge failed: %v", err)
+        }
+
+        got, err := WriteSetFormat(dst)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 description 'template'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth1 address 'dhcp'
+set service ssh port '22'
+set system host-name 'site1'
+comment system host-name 'Set per site'
+set system name-server '8.8.8.8'
+set system name-server '1.1.1.1'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected merge result:\n%s", diff)
+        }
+
+        if len(overrides) != 1 {
+                t.Fatalf("Got %d overrides, want 1", len(overrides))
+        }
+        o := overrides[0]
+        if got := (&SetCommand{Op: SetOpSet, Path: o.Path}).String(); got != "set system host-name 'template'" {
+                t.Errorf("Override path is %q", got)
+        }
+        if *o.Old.Value != "template" || *o.New.Value != "site1" {
+                t.Errorf("Unexpected override %+v", o)
+        }
+
+        // The overlay shouldn't have been modified or shared.
+        if src.TreeSize() != 10 {
+                t.Errorf("Overlay was modified; TreeSize = %d", src.TreeSize())
+        }
+}
+
+func TestMergeReplace(t *testing.T) {
+        configModel := getConfigModel(t)
+        dst, err := ParseSetFormat(mergeBaseConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse base config: %v", err)
+        }
+        src, err := ParseSetFormat(mergeOverlayConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse overlay config: %v", err)
+        }
+
+        overrides, err := MergeWithOptions(dst, src, &MergeOptions{Replace: true})
+        if err != nil {
+                t.Fatalf("Merge failed: %v", err)
+        }
+
+        got, _ := WriteSetFormat(dst)
+        if got != mergeOverlayConfig {
+                edits := myers.ComputeEdits("want", mergeOverlayConfig, got)
+                diff := gotextdiff.ToUnified("want", "got", mergeOverlayConfig, edits)
+                t.Errorf("Unexpected replace result:\n%s", diff)
+        }
+
+        // The removed address, description, ssh service, and
+        // host-name comment, and the changed host name.
+        if len(overrides) != 5 {
+                t.Errorf("Got %d overrides, want 5", len(overrides))
+        }
+}
+
+func TestMergeModelMismatch(t *testing.T) {
+        configModel := getConfigModel(t)
+        dst, err := ParseSetFormat(mergeBaseConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse base config: %v", err)
+        }
+        src, err := ParseSetFormat(mergeOverlayConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse overlay config: %v", err)
+        }
+
+        // Pretend that dst was parsed with a model that doesn't have
+        // `interfaces`.
+        dst.ConfigModel = configModel.FindNodeByName("system")
+        err = Merge(dst, src)
+        var pe *ParseError
+        if !errors.As(err, &pe) || pe.Token != "interfaces" {
+                t.Errorf("Expected a ParseError for `interfaces`, got %v", err)
+        }
+}
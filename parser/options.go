@@ -0,0 +1,139 @@
+package parser
+
+import (
+        "bufio"
+        "errors"
+        "strings"
+)
+
+// ParseOptions controls how the Parse*WithOptions functions behave.
+// The zero value gives the same behavior as the plain Parse*
+// functions.
+type ParseOptions struct {
+        // Filename is copied into any ParseErrors, for reporting.
+        Filename string
+
+        // Lenient tells the parser to keep going after finding a
+        // problem, so that every problem in the config is reported in
+        // one pass.  Lines (or blocks) with errors are skipped, and the
+        // returned AST contains everything that could be parsed.  The
+        // returned error is a ParseErrors holding every problem found.
+        Lenient bool
+}
+
+// WriteOptions controls how the Write*WithOptions functions behave.
+// The zero value gives the same behavior as the plain Write*
+// functions.
+type WriteOptions struct {
+        // Redact replaces secret values, like passwords and private
+        // keys, with `xxxxxx`, the same way as VyOS's `strip-private`.
+        // Values are secret if the config model marks them as secret,
+        // or if they match one of DefaultRedactQueries or
+        // RedactQueries.
+        Redact bool
+
+        // RedactQueries lists extra queries (see Query) for values to
+        // redact, like `service/dns/dynamic/**/username`.
+        RedactQueries []string
+}
+
+// ParseErrors is a list of ParseError, returned by lenient parses.
+type ParseErrors []*ParseError
+
+func (pes ParseErrors) Error() string {
+        lines := []string{}
+        for _, pe := range pes {
+                lines = append(lines, pe.Error())
+        }
+        return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the individual errors, so that errors.As can find a
+// *ParseError inside of a ParseErrors.
+func (pes ParseErrors) Unwrap() []error {
+        errs := []error{}
+        for _, pe := range pes {
+                errs = append(errs, pe)
+        }
+        return errs
+}
+
+// parseState tracks the progress of a single parse, and collects
+// errors in lenient mode.
+type parseState struct {
+        options   *ParseOptions
+        lineno    int
+        column    int // Column of the first non-blank character on the current line
+        truncated bool
+        errors    ParseErrors
+}
+
+func newParseState(options *ParseOptions) *parseState {
+        if options == nil {
+                options = &ParseOptions{}
+        }
+        return &parseState{options: options}
+}
+
+// report records a problem with the config.  It returns the error if
+// parsing should stop, or nil if parsing should continue.
+func (ps *parseState) report(err error) error {
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                // Not a problem with the config itself, so there's
+                // no point in continuing.
+                return err
+        }
+
+        pe.Filename = ps.options.Filename
+        if !ps.options.Lenient {
+                return pe
+        }
+        ps.errors = append(ps.errors, pe)
+        return nil
+}
+
+// err returns the errors collected in lenient mode, or nil if there
+// weren't any.
+func (ps *parseState) err() error {
+        if len(ps.errors) == 0 {
+                return nil
+        }
+        return ps.errors
+}
+
+// checkTruncated is called when a show or config.boot parser runs out
+// of text.  If it was still inside of a `{ ... }` block, then the
+// config is truncated (or just missing a `}`), and this reports an
+// error pointing at the line where the unclosed block started.  Only
+// the innermost unclosed block is reported.
+func (ps *parseState) checkTruncated(path []string, openLine, openColumn int) error {
+        if len(path) == 0 || ps.truncated {
+                return nil
+        }
+        ps.truncated = true
+
+        pe := newParseError(openLine, openColumn, path, "Block is not closed before the end of the config (truncated file?)")
+        pe.Token = "{"
+        return ps.report(pe)
+}
+
+// skipBlock skips over the rest of a `{ ... }` block whose opening
+// line has already been read, so that lenient parses can recover from
+// a bad block.
+func (ps *parseState) skipBlock(scanner *bufio.Scanner) {
+        depth := 1
+        for depth > 0 && scanner.Scan() {
+                ps.lineno++
+                line := strings.TrimSpace(scanner.Text())
+
+                if strings.HasPrefix(line, "//") || strings.HasPrefix(line, "/*") {
+                        continue
+                }
+                if strings.HasPrefix(line, "}") {
+                        depth--
+                } else if strings.HasSuffix(line, "{") {
+                        depth++
+                }
+        }
+}
@@ -0,0 +1,226 @@
+package parser
+
+import (
+        "errors"
+        "fmt"
+        "os"
+        "strings"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+func TestParseSetLenient(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `set system host-name 'router1'
+set interfaces ethernet eth0 adress 'dhcp'
+set interfaces ethernet eth0 address 'dhcp'
+sett system domain-name 'example.com'
+set interfaces ethernet
+set system time-zone 'UTC'
+`
+        ast, err := ParseSetFormatWithOptions(config, configModel, &ParseOptions{Filename: "test.set", Lenient: true})
+        if err == nil {
+                t.Fatalf("Expected errors from invalid config")
+        }
+
+        var pes ParseErrors
+        if !errors.As(err, &pes) {
+                t.Fatalf("Got error of type %T, want ParseErrors", err)
+        }
+
+        wantLines := []int{2, 4, 5}
+        if len(pes) != len(wantLines) {
+                t.Fatalf("Got %d errors, want %d: %v", len(pes), len(wantLines), err)
+        }
+        for i, pe := range pes {
+                if pe.Line != wantLines[i] {
+                        t.Errorf("Error %d: got line %d, want %d", i, pe.Line, wantLines[i])
+                }
+                if pe.Filename != "test.set" {
+                        t.Errorf("Error %d: got Filename=%q, want %q", i, pe.Filename, "test.set")
+                }
+        }
+
+        // errors.As should also find the individual errors.
+        var pe *ParseError
+        if !errors.As(err, &pe) || pe.Line != 2 {
+                t.Errorf("errors.As didn't find the first *ParseError in %v", err)
+        }
+
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        expected := `set system host-name 'router1'
+set system time-zone 'UTC'
+set interfaces ethernet eth0 address 'dhcp'
+`
+        if set != expected {
+                edits := myers.ComputeEdits("foo", expected, set)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", expected, edits))
+                t.Errorf("Generated set-format file does not match expected")
+        }
+}
+
+func TestParseShowLenient(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `interfaces {
+    ethernet eth0 {
+        address 10.0.0.1/24
+        mtu
+        disable-flow-control yes
+        description foo bar
+    }
+    ethernet {
+        mtu 1500
+    }
+    bogus eth1 {
+        address 10.0.0.2/24
+    }
+}
+system {
+    host-name router1
+} extra
+}
+`
+        ast, err := ParseShowFormatWithOptions(config, configModel, &ParseOptions{Lenient: true})
+        var pes ParseErrors
+        if !errors.As(err, &pes) {
+                t.Fatalf("Got error %v, want ParseErrors", err)
+        }
+
+        wantLines := []int{4, 5, 6, 8, 11, 17, 18}
+        if len(pes) != len(wantLines) {
+                t.Fatalf("Got %d errors, want %d: %v", len(pes), len(wantLines), err)
+        }
+        for i, pe := range pes {
+                if pe.Line != wantLines[i] {
+                        t.Errorf("Error %d: got line %d, want %d (%v)", i, pe.Line, wantLines[i], pe)
+                }
+        }
+
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        expected := `set interfaces ethernet eth0 address '10.0.0.1/24'
+set system host-name 'router1'
+`
+        if set != expected {
+                edits := myers.ComputeEdits("foo", expected, set)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", expected, edits))
+                t.Errorf("Generated set-format file does not match expected")
+        }
+
+        // Without Lenient, only the first error is returned.
+        _, err = ParseConfigBootFormatWithOptions(config, configModel, &ParseOptions{Filename: "config.boot"})
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error %v, want *ParseError", err)
+        }
+        if errors.As(err, &pes) {
+                t.Errorf("Got ParseErrors from a strict parse, want a single *ParseError")
+        }
+        if pe.Line != 4 || pe.Filename != "config.boot" {
+                t.Errorf("Got error at %s line %d, want config.boot line 4", pe.Filename, pe.Line)
+        }
+}
+
+func TestParseTruncated(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `interfaces {
+    ethernet eth0 {
+        address "10.0.0.1/24"
+    }
+    ethernet eth1 {
+        address "10.0.0.2/24"
+`
+        for name, parse := range map[string]func(string, *ParseOptions) error{
+                "show": func(c string, o *ParseOptions) error {
+                        _, err := ParseShowFormatWithOptions(c, configModel, o)
+                        return err
+                },
+                "config.boot": func(c string, o *ParseOptions) error {
+                        _, err := ParseConfigBootFormatWithOptions(c, configModel, o)
+                        return err
+                },
+        } {
+                for _, lenient := range []bool{false, true} {
+                        err := parse(config, &ParseOptions{Lenient: lenient})
+                        if err == nil {
+                                t.Errorf("%s (lenient=%v): got no error from truncated config", name, lenient)
+                                continue
+                        }
+
+                        var pe *ParseError
+                        if !errors.As(err, &pe) {
+                                t.Errorf("%s (lenient=%v): got error %v, want *ParseError", name, lenient, err)
+                                continue
+                        }
+                        if pe.Line != 5 || pe.Column != 5 {
+                                t.Errorf("%s (lenient=%v): got error at line %d column %d, want line 5 column 5", name, lenient, pe.Line, pe.Column)
+                        }
+
+                        // Only the innermost unclosed block should be reported.
+                        var pes ParseErrors
+                        if errors.As(err, &pes) && len(pes) != 1 {
+                                t.Errorf("%s (lenient=%v): got %d errors, want 1: %v", name, lenient, len(pes), err)
+                        }
+                }
+        }
+}
+
+func TestParseExtraBrace(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `system {
+    host-name "router1"
+}
+}
+interfaces {
+}
+`
+        _, err := ParseConfigBootFormat(config, configModel)
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error %v, want *ParseError", err)
+        }
+        if pe.Line != 4 {
+                t.Errorf("Got error at line %d, want line 4", pe.Line)
+        }
+
+        _, err = ParseShowFormat(config, configModel)
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error %v, want *ParseError", err)
+        }
+        if pe.Line != 4 {
+                t.Errorf("Got error at line %d, want line 4", pe.Line)
+        }
+}
+
+func TestParseConfigBootTruncatedFile(t *testing.T) {
+        configModel := getConfigModel(t)
+        filename := "testdata/config.boot.1"
+
+        b, err := os.ReadFile(filename)
+        if err != nil {
+                t.Fatalf("Failed to open testdata file %s: %v", filename, err)
+        }
+
+        // Simulate a failed copy by chopping off the end of the file.
+        lines := strings.SplitAfter(string(b), "\n")
+        truncated := strings.Join(lines[:120], "")
+
+        _, err = ParseConfigBootFormat(truncated, configModel)
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error %v from truncated %s, want *ParseError", err, filename)
+        }
+        if pe.Line > 120 {
+                t.Errorf("Got error at line %d, want the line of an unclosed block", pe.Line)
+        }
+        if !strings.HasSuffix(strings.TrimSpace(lines[pe.Line-1]), "{") {
+                t.Errorf("Error points at line %d (%q), which doesn't open a block", pe.Line, lines[pe.Line-1])
+        }
+}
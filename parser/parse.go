@@ -0,0 +1,145 @@
+package parser
+
+import (
+        "bufio"
+        "fmt"
+        "io"
+        "strconv"
+        "strings"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// Format identifies one of the text formats that VyOS uses for
+// configs.
+type Format int
+
+const (
+        FormatUnknown    Format = iota
+        FormatSet               // `set` commands, from `show | commands`
+        FormatShow              // The output of `show` in config mode
+        FormatConfigBoot        // /config/config.boot
+        FormatJSON              // `show configuration json`
+)
+
+func (f Format) String() string {
+        switch f {
+        case FormatSet:
+                return "set"
+        case FormatShow:
+                return "show"
+        case FormatConfigBoot:
+                return "config.boot"
+        case FormatJSON:
+                return "json"
+        }
+        return "unknown"
+}
+
+// DetectFormat guesses which format a config is in.  Configs that
+// start with `{` are JSON, and configs whose first command is `set`,
+// `delete`, or `comment` are in set format.
+// Otherwise, show and config.boot formats only differ in their
+// quoting (config.boot always quotes LeafNode values, show only
+// quotes them when needed) and in config.boot's version footer, so
+// DetectFormat looks for those.  If neither shows up, then show
+// format is assumed when lines are indented by a single space, the
+// way `show configuration` prints them, and config.boot otherwise.
+func DetectFormat(config string) Format {
+        scanner := bufio.NewScanner(strings.NewReader(config))
+        firstLine := ""
+        showVotes, configBootVotes := 0, 0
+
+        for scanner.Scan() {
+                raw := scanner.Text()
+                line := strings.TrimSpace(raw)
+
+                if componentVersionRE.MatchString(line) || releaseVersionRE.MatchString(line) {
+                        return FormatConfigBoot
+                }
+                if line == "" || line[0] == '#' || line[0] == '/' || line[0] == '*' {
+                        continue
+                }
+
+                if firstLine == "" {
+                        firstLine = raw
+                        if line[0] == '{' {
+                                return FormatJSON
+                        }
+                        word := strings.Fields(line)[0]
+                        if word == SetOpSet || word == SetOpDelete || word == SetOpComment {
+                                return FormatSet
+                        }
+                }
+
+                // Only LeafNode lines say anything about quoting; values
+                // on lines that open a block are never quoted.
+                if strings.HasSuffix(line, "{") || strings.HasPrefix(line, "}") {
+                        continue
+                }
+                _, value, found := strings.Cut(line, " ")
+                if !found {
+                        continue
+                }
+                value = strings.TrimSpace(value)
+                if value[0] != '"' {
+                        showVotes++
+                } else if v, err := strconv.Unquote(value); err == nil && showQuoteRE.MatchString(v) {
+                        // Show format wouldn't have quoted this.
+                        configBootVotes++
+                }
+        }
+
+        switch {
+        case firstLine == "":
+                return FormatUnknown
+        case showVotes > configBootVotes:
+                return FormatShow
+        case configBootVotes > showVotes:
+                return FormatConfigBoot
+        case strings.HasPrefix(firstLine, " ") && !strings.HasPrefix(firstLine, "  "):
+                return FormatShow
+        }
+        return FormatConfigBoot
+}
+
+// Parse detects the format of a config using DetectFormat and parses
+// it with ParseSetFormat, ParseShowFormat, ParseConfigBootFormat, or
+// ParseJSONFormat.
+// It returns the format that was detected along with the AST.
+func Parse(config string, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, Format, error) {
+        return ParseWithOptions(config, configModel, nil)
+}
+
+// ParseWithOptions is like Parse, but takes a ParseOptions to control
+// parsing.
+func ParseWithOptions(config string, configModel *configmodel.VyOSConfigNode, options *ParseOptions) (*VyOSConfigAST, Format, error) {
+        format := DetectFormat(config)
+
+        var ast *VyOSConfigAST
+        var err error
+        switch format {
+        case FormatSet:
+                ast, err = ParseSetFormatWithOptions(config, configModel, options)
+        case FormatShow:
+                ast, err = ParseShowFormatWithOptions(config, configModel, options)
+        case FormatConfigBoot:
+                ast, err = ParseConfigBootFormatWithOptions(config, configModel, options)
+        case FormatJSON:
+                ast, err = ParseJSONFormatWithOptions(config, configModel, options)
+        default:
+                // Nothing but blank lines and comments, so there's
+                // nothing to parse.
+                ast = &VyOSConfigAST{Child: &Node{Type: "root"}, ConfigModel: configModel}
+        }
+        return ast, format, err
+}
+
+// ParseReader is like Parse, but reads the config from an io.Reader.
+func ParseReader(r io.Reader, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, Format, error) {
+        b, err := io.ReadAll(r)
+        if err != nil {
+                return nil, FormatUnknown, fmt.Errorf("Error occurred while reading config: %v", err)
+        }
+        return Parse(string(b), configModel)
+}
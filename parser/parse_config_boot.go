@@ -7,100 +7,280 @@ import (
         "strconv"
 
         "github.com/scottlaird/vyos-parser/configmodel"
+        "github.com/scottlaird/vyos-parser/syntax"
 )
 
 
 // ParseConfigBootFormat takes a VyOS text configuration in
 // `config.boot` format and returns a VyOSConfigAST and/or an error.
+// The version footer at the end of the file, if any, is recorded in
+// the AST's ComponentVersions and ReleaseVersion.
 func ParseConfigBootFormat(config string, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, error) {
+        return ParseConfigBootFormatWithOptions(config, configModel, nil)
+}
+
+// ParseConfigBootFormatWithOptions is like ParseConfigBootFormat, but
+// takes a ParseOptions to control parsing.
+func ParseConfigBootFormatWithOptions(config string, configModel *configmodel.VyOSConfigNode, options *ParseOptions) (*VyOSConfigAST, error) {
         ast := &VyOSConfigAST{}
         child := &Node{
                 Type: "root",
         }
         ast.Child = child
+        ast.ConfigModel = configModel
         scanner := bufio.NewScanner(strings.NewReader(config))
+        ps := newParseState(options)
 
         if err := scanner.Err(); err != nil {
                 return ast, fmt.Errorf("Error occurred while scanning: %v", err)
         }
 
-        lineno := 0
-        err := parseConfigBootFormat(child, scanner, configModel, &lineno)
-        return ast, err
+        if err := parseVersionFooter(ast, config); err != nil {
+                if err := ps.report(err); err != nil {
+                        return ast, err
+                }
+        }
+
+        if err := parseConfigBootFormat(child, scanner, configModel, ps, []string{}); err != nil {
+                return ast, err
+        }
+        return ast, ps.err()
+}
+
+// ParseConfigBootFormatAutoModel is like ParseConfigBootFormat, but
+// it picks the embedded config model to use based on the `Release
+// version:` footer in the config, so that older configs are parsed
+// with the syntax that they were written against.  It returns the
+// name of the model that was used along with the AST.  Configs
+// without a dated release use the default model.
+func ParseConfigBootFormatAutoModel(config string) (*VyOSConfigAST, string, error) {
+        footer := &VyOSConfigAST{}
+        if err := parseVersionFooter(footer, config); err != nil {
+                return nil, "", err
+        }
+
+        configModel, version, err := syntax.GetConfigModelForRelease(footer.ReleaseVersion)
+        if err != nil {
+                return nil, "", err
+        }
+
+        ast, err := ParseConfigBootFormat(config, configModel)
+        return ast, version, err
 }
 
 // parseConfigBootFormat parses everything underneath a higher-level
 // node in the config.  It reads from the `config` scanner and updates
 // `nodeContext` as needed, returning an error if it's unable to parse.
-func parseConfigBootFormat(nodeContext *Node, scanner *bufio.Scanner, configModel *configmodel.VyOSConfigNode, lineno *int) error {
+// `path` holds the words leading up to `nodeContext`, for error
+// reporting.  Running out of text before the closing `}` of a block
+// is an error.
+func parseConfigBootFormat(nodeContext *Node, scanner *bufio.Scanner, configModel *configmodel.VyOSConfigNode, ps *parseState, path []string) error {
+        // Comments appear on the line(s) before the node that
+        // they're attached to.
+        comment := ""
+
+        // Remember where this block started, for reporting
+        // unclosed blocks.
+        openLine, openColumn := ps.lineno, ps.column
+
         for scanner.Scan() {
                 line := scanner.Text()
-                (*lineno)++
+                ps.lineno++
+                column := len(line) - len(strings.TrimLeft(line, " \t")) + 1
+                ps.column = column
                 
                 line = strings.TrimSpace(line)
 
-                if len(line)==0 || line[0]=='/' {
+                if len(line)==0 || strings.HasPrefix(line, "//") {
+                        continue
+                }
+
+                if strings.HasPrefix(line, "/*") {
+                        text, err := readComment(line, column, scanner, &ps.lineno)
+                        if err != nil {
+                                return ps.report(err)
+                        }
+                        comment = text
                         continue
                 }
                 
                 if line[0] == '}' {
-                        return nil
+                        done, err := closeBlock(line, column, path, ps)
+                        if err != nil {
+                                return err
+                        }
+                        if done {
+                                return nil
+                        }
+                        continue
                 }
 
                 name := strings.SplitN(line, " ", 2)[0]
+                opensBlock := line[len(line)-1] == '{'
 
                 configNode := configModel.FindNodeByName(name)
                 if configNode == nil {
-                        return fmt.Errorf("Couldn't match config token %q at line number %d", name, *lineno)
+                        if err := ps.report(newUnknownWordError(ps.lineno, column, path, name, configModel)); err != nil {
+                                return err
+                        }
+                        if opensBlock {
+                                ps.skipBlock(scanner)
+                        }
+                        continue
+                }
+
+                value, err := parseLineValue(line, name, column, path, configNode, ps.lineno)
+                if err != nil {
+                        if err := ps.report(err); err != nil {
+                                return err
+                        }
+                        if opensBlock {
+                                ps.skipBlock(scanner)
+                        }
+                        continue
                 }
 
                 // This can't use `addNode` because that tries to
                 // merge children with the same name, which breaks
                 // with TagNodes or LeafNodes with multi=true
                 astNode := newASTNode(configNode)
+                astNode.Value = value
+                astNode.Comment = comment
+                comment = ""
                 nodeContext.Children = append(nodeContext.Children, astNode)
                 
-                remainingLine := line[len(name):]
-                if len(remainingLine)>0 {
-                        if remainingLine[len(remainingLine)-1] == '{' {
-                                remainingLine = remainingLine[:len(remainingLine)-1] // strip "{" and whitespace from the end.
+                if opensBlock {
+                        childPath := append(path, name)
+                        if astNode.Value != nil {
+                                childPath = append(childPath, *astNode.Value)
                         }
-                        remainingLine = strings.TrimSpace(remainingLine)
-
-                        if len(remainingLine)>0 && remainingLine[0]=='"' {
-                                value, err := strconv.Unquote(remainingLine)
-                                if err != nil {
-                                        fmt.Printf("Unquote error: %v\n", err)
-                                        return err
-                                }
-                                astNode.Value = &value
-                        } else {
-                                if len(remainingLine)>0 {
-                                        // Not completely happy about this
-                                        astNode.Value = &remainingLine
-                                        //fmt.Printf("Found extra value of %q in %q at line %d\n", remainingLine, line, *lineno)
-                                }
+                        if err := parseConfigBootFormat(astNode, scanner, configNode, ps, childPath); err != nil {
+                                return err
                         }
                 }
+        }
 
-                
-                if line[len(line)-1] == '{' {
-                        parseConfigBootFormat(astNode, scanner, configNode, lineno)
+        // Ran out of text.
+        return ps.checkTruncated(path, openLine, openColumn)
+}
+
+// parseLineValue finds the value (if any) on a line from a `show` or
+// `config.boot` format config, and checks it against `configNode`.
+// It returns nil if the line doesn't have a value.
+func parseLineValue(line, name string, column int, path []string, configNode *configmodel.VyOSConfigNode, lineno int) (*string, error) {
+        var value *string
+
+        remainingLine := line[len(name):]
+        remainingLine = strings.TrimSuffix(remainingLine, "{") // strip "{" and whitespace from the end.
+        remainingLine = strings.TrimSpace(remainingLine)
+
+        if len(remainingLine)>0 && remainingLine[0]=='"' {
+                v, err := strconv.Unquote(remainingLine)
+                if err != nil {
+                        return nil, newUnquoteError(lineno, column, path, remainingLine, err)
                 }
+                value = &v
+        } else if len(remainingLine)>0 {
+                // Unquoted values can't contain spaces, so
+                // anything after the first word is garbage.
+                if i := strings.IndexAny(remainingLine, " \t"); i >= 0 {
+                        pe := newParseError(lineno, column+len(name)+1+i, path, fmt.Sprintf("Unexpected text %q after value of %q", strings.TrimSpace(remainingLine[i:]), name))
+                        pe.Token = strings.TrimSpace(remainingLine[i:])
+                        return nil, pe
+                }
+                value = &remainingLine
+        }
+
+        if value == nil && configNode.HasValue && configNode.Type != "node" {
+                pe := newParseError(lineno, column, path, fmt.Sprintf("Missing value for %q", name))
+                pe.Token = name
+                return nil, pe
+        }
+        if value != nil && !configNode.HasValue {
+                pe := newParseError(lineno, column+len(name)+1, path, fmt.Sprintf("Unexpected value %q for %q", *value, name))
+                pe.Token = *value
+                return nil, pe
         }
 
-        // Ran out of text?
-        return nil
+        return value, nil
 }
 
+// closeBlock handles a line that starts with `}`.  It returns true
+// if this closes the current block, and false if the `}` should be
+// ignored because it doesn't match anything.
+func closeBlock(line string, column int, path []string, ps *parseState) (bool, error) {
+        if rest := strings.TrimSpace(line[1:]); rest != "" {
+                pe := newParseError(ps.lineno, column+1, path, fmt.Sprintf("Unexpected text %q after '}'", rest))
+                pe.Token = rest
+                if err := ps.report(pe); err != nil {
+                        return false, err
+                }
+        }
+
+        if len(path) == 0 {
+                pe := newParseError(ps.lineno, column, path, "Unexpected '}' with no matching '{'")
+                pe.Token = "}"
+                return false, ps.report(pe)
+        }
+        return true, nil
+}
+
+// WriteConfigBootFormat returns the config.boot format of the
+// specified config AST, including the version footer if the AST has
+// version information.
 func WriteConfigBootFormat(ast *VyOSConfigAST) (string, error) {
+        return WriteConfigBootFormatWithOptions(ast, nil)
+}
+
+// WriteConfigBootFormatWithOptions is like WriteConfigBootFormat, but
+// takes a WriteOptions to control the output.
+func WriteConfigBootFormatWithOptions(ast *VyOSConfigAST, options *WriteOptions) (string, error) {
+        ast, err := options.prepare(ast)
+        if err != nil {
+                return "", err
+        }
         results, err := writeConfigBootPartial(ast.Child, 0)
         if err != nil {
                 return "", err
         }
+        results = append(results, writeVersionFooter(ast)...)
         return strings.Join(results, "\n")+"\n", nil
 }
 
+// readComment reads a C-style `/* ... */` comment, starting with
+// `line`.  Comments can span multiple lines, so this may need to read
+// more lines from `scanner`.  It returns the text inside of the
+// comment markers.
+func readComment(line string, column int, scanner *bufio.Scanner, lineno *int) (string, error) {
+        startLine := *lineno
+        text := strings.TrimPrefix(line, "/*")
+        lines := []string{}
+
+        for {
+                if end := strings.Index(text, "*/"); end >= 0 {
+                        lines = append(lines, strings.TrimSpace(text[:end]))
+                        break
+                }
+                lines = append(lines, strings.TrimSpace(text))
+
+                if !scanner.Scan() {
+                        return "", newParseError(startLine, column, nil, "Unterminated comment")
+                }
+                (*lineno)++
+                text = scanner.Text()
+        }
+
+        return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+// writeComment returns the `/* ... */` line that VyOS writes before
+// nodes with comments attached.  Multi-line comments have each line
+// indented to match the node.
+func writeComment(comment string, indent int) string {
+        comment = strings.ReplaceAll(comment, "\n", "\n"+spaces(indent))
+        return spaces(indent) + "/* " + comment + " */"
+}
+
 // spaces returns a string with a specific number of space characters,
 // used for indenting.
 func spaces(indent int) string {
@@ -128,6 +308,10 @@ func writeConfigBootPartial(node *Node, indent int) ([]string, error) {
         }
         
 
+        if node.Comment != "" && line != "" {
+                results = append(results, writeComment(node.Comment, indent))
+        }
+
         if node.Type == "leafnode" {
                 results = append(results, spaces(indent) + line)
         } else {
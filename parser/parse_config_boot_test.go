@@ -3,7 +3,6 @@ package parser
 import (
         "fmt"
         "os"
-        "strings"
         "testing"
 
         "github.com/hexops/gotextdiff"
@@ -36,19 +35,6 @@ func TestParseConfigBootRoundTrip(t *testing.T) {
                 t.Fatalf("Failed calling writeConfigBootFormat: %v", err)
         }
 
-        // Now, the original file has a bunch of version comments in
-        // it which we don't decode.  Because of that, we want to
-        // compare *without* the comments, so let's strip them here.
-        //
-        // Also remove completely blank lines.
-        lines := []string{}
-        for _, line := range strings.SplitAfter(originalCBConfig, "\n") {
-                if !strings.HasPrefix(line, "//") && line != "\n" {
-                        lines = append(lines, line)
-                }
-        }
-        originalCBConfig = strings.Join(lines, "")
-
         if newCBConfig != originalCBConfig {
                 edits := myers.ComputeEdits("foo", originalCBConfig, newCBConfig)
                 fmt.Println(gotextdiff.ToUnified(filename, "output", originalCBConfig, edits))
@@ -95,3 +81,174 @@ func TestParseConfigBootToSet(t *testing.T) {
         }
 
 }
+
+func TestParseConfigBootComments(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `interfaces {
+    /* Uplink to the core switch */
+    ethernet eth0 {
+        address "10.0.0.1/24"
+        /* Don't touch
+        this without asking */
+        mtu "9000"
+    }
+}
+system {
+    host-name "router1"
+}
+`
+        ast, err := ParseConfigBootFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse static config: %v", err)
+        }
+
+        eth0 := ast.Child.Children[0].Children[0]
+        if eth0.Comment != "Uplink to the core switch" {
+                t.Errorf("Got comment %q on eth0, want %q", eth0.Comment, "Uplink to the core switch")
+        }
+
+        cb, err := WriteConfigBootFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeConfigBootFormat: %v", err)
+        }
+        if cb != config {
+                edits := myers.ComputeEdits("foo", config, cb)
+                fmt.Println(gotextdiff.ToUnified("input", "output", config, edits))
+                t.Errorf("Generated config.boot file does not match")
+        }
+
+        show, err := WriteShowFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeShowFormat: %v", err)
+        }
+        ast2, err := ParseShowFormat(show, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse generated show config: %v", err)
+        }
+        show2, err := WriteShowFormat(ast2)
+        if err != nil {
+                t.Fatalf("Failed calling writeShowFormat: %v", err)
+        }
+        if show != show2 {
+                edits := myers.ComputeEdits("foo", show, show2)
+                fmt.Println(gotextdiff.ToUnified("input", "output", show, edits))
+                t.Errorf("Show-format comments did not round-trip")
+        }
+
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        wantSet := `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 mtu '9000'
+comment interfaces ethernet eth0 mtu 'Don'\''t touch\nthis without asking'
+comment interfaces ethernet eth0 'Uplink to the core switch'
+set system host-name 'router1'
+`
+        if set != wantSet {
+                edits := myers.ComputeEdits("foo", wantSet, set)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", wantSet, edits))
+                t.Errorf("Generated set-format file does not match")
+        }
+
+        ast3, err := ParseSetFormat(set, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse generated set config: %v", err)
+        }
+        set3, err := WriteSetFormat(ast3)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        if set3 != set {
+                edits := myers.ComputeEdits("foo", set, set3)
+                fmt.Println(gotextdiff.ToUnified("input", "output", set, edits))
+                t.Errorf("Set-format comments did not round-trip")
+        }
+}
+
+func TestParseConfigBootVersionFooter(t *testing.T) {
+        configModel := getConfigModel(t)
+        filename := "testdata/config.boot.1"
+
+        b, err := os.ReadFile(filename)
+        if err != nil {
+                t.Fatalf("Failed to open testdata file %s: %v", filename, err)
+        }
+
+        ast, err := ParseConfigBootFormat(string(b), configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse %s: %v", filename, err)
+        }
+
+        want := "1.5-rolling-202501060800"
+        if ast.ReleaseVersion != want {
+                t.Errorf("Got ReleaseVersion=%q, want %q", ast.ReleaseVersion, want)
+        }
+
+        wantComponents := 49
+        if len(ast.ComponentVersions) != wantComponents {
+                t.Errorf("Got %d component versions, want %d", len(ast.ComponentVersions), wantComponents)
+        }
+
+        tests := []struct {
+                component string
+                version   int
+                found     bool
+        }{
+                {"bgp", 5, true},
+                {"interfaces", 33, true},
+                {"wanloadbalance", 3, true},
+                {"no-such-component", 0, false},
+        }
+        for _, test := range tests {
+                version, found := ast.ComponentVersion(test.component)
+                if version != test.version || found != test.found {
+                        t.Errorf("ComponentVersion(%q) = %d, %v, want %d, %v", test.component, version, found, test.version, test.found)
+                }
+        }
+
+        legacy := `system {
+    host-name "router1"
+}
+/* Warning: Do not remove the following line. */
+/* === vyatta-config-version: "broadcast-relay@1:system@10" === */
+/* Release version: 1.2.6 */
+`
+        ast, err = ParseConfigBootFormat(legacy, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse legacy config: %v", err)
+        }
+        if ast.ReleaseVersion != "1.2.6" {
+                t.Errorf("Got ReleaseVersion=%q from legacy footer, want %q", ast.ReleaseVersion, "1.2.6")
+        }
+        if version, _ := ast.ComponentVersion("system"); version != 10 {
+                t.Errorf("Got system version %d from legacy footer, want 10", version)
+        }
+}
+
+func TestParseConfigBootAutoModel(t *testing.T) {
+        filename := "testdata/config.boot.1"
+
+        b, err := os.ReadFile(filename)
+        if err != nil {
+                t.Fatalf("Failed to open testdata file %s: %v", filename, err)
+        }
+
+        ast, version, err := ParseConfigBootFormatAutoModel(string(b))
+        if err != nil {
+                t.Fatalf("Failed to parse %s: %v", filename, err)
+        }
+
+        // The config was written by 1.5-rolling-202501060800, and
+        // the oldest embedded model is from 20250126.
+        want := "vyos-20250126"
+        if version != want {
+                t.Errorf("Got model version %q, want %q", version, want)
+        }
+
+        treesize := ast.TreeSize()
+        wantSize := 162
+        if treesize != wantSize {
+                t.Errorf("Got treesize=%d, want %d", treesize, wantSize)
+        }
+}
@@ -0,0 +1,377 @@
+package parser
+
+import (
+        "bytes"
+        "encoding/json"
+        "fmt"
+        "strings"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// VyOS can also produce configs as JSON, via `show configuration
+// json` or the HTTP API's `showConfig`.  The mapping is:
+//
+//      Nodes           {"interfaces": {...}}
+//      TagNodes        {"ethernet": {"eth0": {...}, "eth1": {...}}}
+//      LeafNodes       {"description": "uplink"}
+//      Multi LeafNodes {"address": ["10.0.0.1/24", "10.0.0.2/24"]}
+//      Valueless       {"disable-flow-control": {}}
+//
+// Go maps don't preserve key order, so reading and writing go
+// through jsonObject, which does.
+
+// jsonMember is a single key/value pair in a JSON object.  JSON
+// doesn't have comments or useful line numbers, but YAML does, so
+// they're carried here too.
+type jsonMember struct {
+        Key     string
+        Value   any
+        Line    int
+        Comment string
+}
+
+// jsonObject is a JSON object that keeps its keys in order.
+type jsonObject struct {
+        Members []*jsonMember
+}
+
+// get returns the value for `key`, or nil if it isn't present.
+func (jo *jsonObject) get(key string) any {
+        for _, m := range jo.Members {
+                if m.Key == key {
+                        return m.Value
+                }
+        }
+        return nil
+}
+
+// merge adds `value` to the object under `key`.  If `key` already
+// holds an object, then the two objects are merged.
+func (jo *jsonObject) merge(key string, value *jsonObject) *jsonMember {
+        existing, ok := jo.get(key).(*jsonObject)
+        if !ok {
+                return jo.set(key, value)
+        }
+        for _, m := range value.Members {
+                if child, ok := m.Value.(*jsonObject); ok {
+                        existing.merge(m.Key, child)
+                } else {
+                        existing.set(m.Key, m.Value)
+                }
+        }
+        return jo.set(key, existing)
+}
+
+// set adds `key` to the object, replacing any existing value.  It
+// returns the member that holds `key`.
+func (jo *jsonObject) set(key string, value any) *jsonMember {
+        for _, m := range jo.Members {
+                if m.Key == key {
+                        m.Value = value
+                        return m
+                }
+        }
+        m := &jsonMember{Key: key, Value: value}
+        jo.Members = append(jo.Members, m)
+        return m
+}
+
+func (jo *jsonObject) MarshalJSON() ([]byte, error) {
+        var buf bytes.Buffer
+        buf.WriteByte('{')
+        for i, m := range jo.Members {
+                if i > 0 {
+                        buf.WriteByte(',')
+                }
+                key, err := json.Marshal(m.Key)
+                if err != nil {
+                        return nil, err
+                }
+                value, err := json.Marshal(m.Value)
+                if err != nil {
+                        return nil, err
+                }
+                buf.Write(key)
+                buf.WriteByte(':')
+                buf.Write(value)
+        }
+        buf.WriteByte('}')
+        return buf.Bytes(), nil
+}
+
+// decodeJSONValue reads a single JSON value from `dec`, returning
+// objects as *jsonObject so that key order is preserved.
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+        token, err := dec.Token()
+        if err != nil {
+                return nil, err
+        }
+
+        switch t := token.(type) {
+        case json.Delim:
+                switch t {
+                case '{':
+                        obj := &jsonObject{}
+                        for dec.More() {
+                                keyToken, err := dec.Token()
+                                if err != nil {
+                                        return nil, err
+                                }
+                                value, err := decodeJSONValue(dec)
+                                if err != nil {
+                                        return nil, err
+                                }
+                                obj.Members = append(obj.Members, &jsonMember{Key: keyToken.(string), Value: value})
+                        }
+                        _, err := dec.Token() // closing '}'
+                        return obj, err
+                case '[':
+                        list := []any{}
+                        for dec.More() {
+                                value, err := decodeJSONValue(dec)
+                                if err != nil {
+                                        return nil, err
+                                }
+                                list = append(list, value)
+                        }
+                        _, err := dec.Token() // closing ']'
+                        return list, err
+                }
+                return nil, fmt.Errorf("Unexpected JSON delimiter %v", t)
+        }
+        return token, nil
+}
+
+// ParseJSONFormat takes a VyOS configuration in JSON format and
+// returns a VyOSConfigAST and/or an error.  The structure of the JSON
+// is checked against `configModel`.
+func ParseJSONFormat(config string, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, error) {
+        return ParseJSONFormatWithOptions(config, configModel, nil)
+}
+
+// ParseJSONFormatWithOptions is like ParseJSONFormat, but takes a
+// ParseOptions to control parsing.  JSON doesn't have meaningful line
+// numbers, so errors report the config path instead.
+func ParseJSONFormatWithOptions(config string, configModel *configmodel.VyOSConfigNode, options *ParseOptions) (*VyOSConfigAST, error) {
+        ast := &VyOSConfigAST{}
+        child := &Node{
+                Type: "root",
+        }
+        ast.Child = child
+        ast.ConfigModel = configModel
+        ps := newParseState(options)
+
+        dec := json.NewDecoder(strings.NewReader(config))
+        dec.UseNumber()
+        value, err := decodeJSONValue(dec)
+        if err != nil {
+                return ast, fmt.Errorf("Failed to decode JSON: %v", err)
+        }
+
+        obj, ok := value.(*jsonObject)
+        if !ok {
+                if err := ps.report(newParseError(0, 0, nil, "Top level of JSON config is not an object")); err != nil {
+                        return ast, err
+                }
+                return ast, ps.err()
+        }
+
+        if err := parseJSONObject(child, obj, configModel, ps, []string{}); err != nil {
+                return ast, err
+        }
+        return ast, ps.err()
+}
+
+// parseJSONObject adds the members of `obj` underneath `nodeContext`,
+// using `configModel` to decide how to interpret each one.
+func parseJSONObject(nodeContext *Node, obj *jsonObject, configModel *configmodel.VyOSConfigNode, ps *parseState, path []string) error {
+        for _, m := range obj.Members {
+                configNode := configModel.FindNodeByName(m.Key)
+                if configNode == nil {
+                        if err := ps.report(newUnknownWordError(m.Line, 0, path, m.Key, configModel)); err != nil {
+                                return err
+                        }
+                        continue
+                }
+
+                if err := parseJSONMember(nodeContext, m, configNode, ps, append(path, m.Key)); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// parseJSONMember adds a single member of a JSON object to the AST.
+func parseJSONMember(nodeContext *Node, m *jsonMember, configNode *configmodel.VyOSConfigNode, ps *parseState, path []string) error {
+        switch configNode.Type {
+        case "node":
+                obj, ok := m.Value.(*jsonObject)
+                if !ok {
+                        return ps.report(newJSONTypeError(m.Line, path, "an object", m.Value))
+                }
+                child := nodeContext.addNode(configNode, nil)
+                child.Comment = m.Comment
+                return parseJSONObject(child, obj, configNode, ps, path)
+
+        case "tagnode":
+                obj, ok := m.Value.(*jsonObject)
+                if !ok {
+                        return ps.report(newJSONTypeError(m.Line, path, "an object", m.Value))
+                }
+                for _, entry := range obj.Members {
+                        entryPath := append(path, entry.Key)
+                        entryObj, ok := entry.Value.(*jsonObject)
+                        if !ok {
+                                if err := ps.report(newJSONTypeError(entry.Line, entryPath, "an object", entry.Value)); err != nil {
+                                        return err
+                                }
+                                continue
+                        }
+                        value := entry.Key
+                        child := nodeContext.addNode(configNode, &value)
+                        child.Comment = entry.Comment
+                        if err := parseJSONObject(child, entryObj, configNode, ps, entryPath); err != nil {
+                                return err
+                        }
+                }
+                return nil
+
+        case "leafnode":
+                if !configNode.HasValue {
+                        // VyOS uses `{}`, but accept anything else
+                        // that clearly means "present" too.
+                        switch v := m.Value.(type) {
+                        case *jsonObject:
+                                if len(v.Members) != 0 {
+                                        return ps.report(newJSONTypeError(m.Line, path, "an empty object", m.Value))
+                                }
+                        case nil:
+                        case bool:
+                                if !v {
+                                        return nil
+                                }
+                        default:
+                                return ps.report(newJSONTypeError(m.Line, path, "an empty object", m.Value))
+                        }
+                        child := nodeContext.addNode(configNode, nil)
+                        child.Comment = m.Comment
+                        return nil
+                }
+
+                values := []any{m.Value}
+                if list, ok := m.Value.([]any); ok {
+                        if !configNode.Multi && len(list) != 1 {
+                                return ps.report(newJSONTypeError(m.Line, path, "a single value", m.Value))
+                        }
+                        values = list
+                }
+                for i, v := range values {
+                        value, ok := jsonScalar(v)
+                        if !ok {
+                                if err := ps.report(newJSONTypeError(m.Line, path, "a string", v)); err != nil {
+                                        return err
+                                }
+                                continue
+                        }
+                        child := nodeContext.addNode(configNode, &value)
+                        if i == 0 && m.Comment != "" {
+                                child.Comment = m.Comment
+                        }
+                }
+                return nil
+        }
+
+        return ps.report(newParseError(m.Line, 0, path, fmt.Sprintf("Unknown config node type %q", configNode.Type)))
+}
+
+// jsonScalar converts a JSON string, number, or boolean into a
+// string.
+func jsonScalar(v any) (string, bool) {
+        switch s := v.(type) {
+        case string:
+                return s, true
+        case json.Number:
+                return s.String(), true
+        case bool:
+                return fmt.Sprintf("%v", s), true
+        }
+        return "", false
+}
+
+// newJSONTypeError returns a ParseError for a JSON value of the wrong
+// type.
+func newJSONTypeError(lineno int, path []string, want string, got any) *ParseError {
+        b, _ := json.Marshal(got)
+        pe := newParseError(lineno, 0, path, fmt.Sprintf("Expected %s, got %s", want, b))
+        pe.Token = string(b)
+        return pe
+}
+
+// WriteJSONFormat returns the JSON format of the specified config
+// AST, indented the same way as VyOS's `show configuration json
+// pretty`.
+func WriteJSONFormat(ast *VyOSConfigAST) (string, error) {
+        obj, err := writeJSONPartial(ast.Child)
+        if err != nil {
+                return "", err
+        }
+        b, err := json.MarshalIndent(obj, "", "    ")
+        if err != nil {
+                return "", err
+        }
+        return string(b) + "\n", nil
+}
+
+// writeJSONPartial turns the children of `node` into a jsonObject.
+func writeJSONPartial(node *Node) (*jsonObject, error) {
+        obj := &jsonObject{}
+
+        for _, child := range node.Children {
+                name := child.ContextNode.Name
+                var m *jsonMember
+
+                switch child.Type {
+                case "tagnode":
+                        if child.Value == nil {
+                                return nil, fmt.Errorf("TagNode %q is missing a value", name)
+                        }
+                        entries, ok := obj.get(name).(*jsonObject)
+                        if !ok {
+                                entries = &jsonObject{}
+                                obj.set(name, entries)
+                        }
+                        childObj, err := writeJSONPartial(child)
+                        if err != nil {
+                                return nil, err
+                        }
+                        m = entries.merge(*child.Value, childObj)
+
+                case "leafnode":
+                        switch {
+                        case child.Value == nil:
+                                m = obj.set(name, &jsonObject{})
+                        case child.ContextNode.Multi:
+                                values, _ := obj.get(name).([]string)
+                                m = obj.set(name, append(values, *child.Value))
+                        default:
+                                m = obj.set(name, *child.Value)
+                        }
+
+                default:
+                        childObj, err := writeJSONPartial(child)
+                        if err != nil {
+                                return nil, err
+                        }
+                        // config.boot format can repeat a node, so
+                        // merge rather than replace.
+                        m = obj.merge(name, childObj)
+                }
+
+                if child.Comment != "" {
+                        m.Comment = child.Comment
+                }
+        }
+
+        return obj, nil
+}
@@ -0,0 +1,150 @@
+package parser
+
+import (
+        "errors"
+        "fmt"
+        "os"
+        "slices"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+func TestParseJSONRoundTrip(t *testing.T) {
+        configModel := getConfigModel(t)
+        filename := "testdata/config.set.1"
+
+        b, err := os.ReadFile(filename)
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        originalSetConfig := string(b)
+
+        ast, err := ParseSetFormat(originalSetConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse %s: %v", filename, err)
+        }
+
+        jsonConfig, err := WriteJSONFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling WriteJSONFormat: %v", err)
+        }
+
+        ast2, err := ParseJSONFormat(jsonConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse generated JSON: %v", err)
+        }
+
+        treesize := ast2.TreeSize()
+        want := 162
+        if treesize != want {
+                t.Errorf("Got treesize=%d, want %d", treesize, want)
+        }
+
+        newSetConfig, err := WriteSetFormat(ast2)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+
+        if newSetConfig != originalSetConfig {
+                edits := myers.ComputeEdits("foo", originalSetConfig, newSetConfig)
+                fmt.Println(gotextdiff.ToUnified(filename, "output", originalSetConfig, edits))
+                t.Errorf("Set-format config does not match after round-trip through JSON")
+        }
+}
+
+func TestWriteJSONFormat(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth0 disable-flow-control
+set interfaces ethernet eth0 description 'uplink'
+set interfaces ethernet eth1 ipv6
+`
+        ast, err := ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse static config: %v", err)
+        }
+
+        got, err := WriteJSONFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling WriteJSONFormat: %v", err)
+        }
+
+        expected := `{
+    "interfaces": {
+        "ethernet": {
+            "eth0": {
+                "address": [
+                    "10.0.0.1/24",
+                    "10.0.0.2/24"
+                ],
+                "disable-flow-control": {},
+                "description": "uplink"
+            },
+            "eth1": {
+                "ipv6": {}
+            }
+        }
+    }
+}
+`
+        if got != expected {
+                edits := myers.ComputeEdits("foo", expected, got)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", expected, edits))
+                t.Errorf("Generated JSON does not match expected")
+        }
+}
+
+func TestParseJSONErrors(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `{
+    "interfaces": {
+        "ethernet": {
+            "eth0": {
+                "adress": "10.0.0.1/24",
+                "mtu": ["1500", "9000"],
+                "description": "uplink"
+            }
+        }
+    },
+    "system": {
+        "host-name": {"foo": "bar"}
+    }
+}`
+        ast, err := ParseJSONFormatWithOptions(config, configModel, &ParseOptions{Lenient: true})
+
+        var pes ParseErrors
+        if !errors.As(err, &pes) {
+                t.Fatalf("Got error %v, want ParseErrors", err)
+        }
+        if len(pes) != 3 {
+                t.Fatalf("Got %d errors, want 3: %v", len(pes), err)
+        }
+
+        wantPath := []string{"interfaces", "ethernet", "eth0"}
+        if !slices.Equal(pes[0].Path, wantPath) || pes[0].Suggestions[0] != "address" {
+                t.Errorf("Got error %v, want unknown word under %v", pes[0], wantPath)
+        }
+
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        // `system` is still there, just without the bad `host-name`.
+        expected := "set interfaces ethernet eth0 description 'uplink'\nset system\n"
+        if set != expected {
+                t.Errorf("Got %q from lenient parse, want %q", set, expected)
+        }
+
+        if _, err := ParseJSONFormat(`["not", "an", "object"]`, configModel); err == nil {
+                t.Errorf("Expected error from non-object JSON")
+        }
+
+        // Lenient mode still has to report a bad top level.
+        _, err = ParseJSONFormatWithOptions(`[1, 2]`, configModel, &ParseOptions{Lenient: true})
+        if !errors.As(err, &pes) || len(pes) != 1 {
+                t.Errorf("Got error %v from lenient non-object JSON, want 1 ParseError", err)
+        }
+}
@@ -6,6 +6,7 @@ import (
         "regexp"
         "strconv"
         "strings"
+        "unicode"
 
         "github.com/kballard/go-shellquote"
         "github.com/scottlaird/vyos-parser/configmodel"
@@ -32,7 +33,9 @@ func quoteIfNeeded(value string) string {
 }
 
 // ParseSetFormat takes a VyOS text configuration in
-// `set` format and returns a VyOSConfigAST and/or an error.
+// `set` format and returns a VyOSConfigAST and/or an error.  Besides
+// `set` commands, it also accepts `delete` commands, which are
+// applied against the config built up by the lines before them.
 //
 // Note that VyOS's config outputter (`show | commands`) isn't very
 // consistent about quoting.  Examples, from VyOS 1.5 202501xxx:
@@ -49,40 +52,111 @@ func quoteIfNeeded(value string) string {
 // `set service ntp server` and `set system name-server` lines -- they
 // both contain an IP address here, but one is quoted and one isn't.
 func ParseSetFormat(config string, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, error) {
+        return ParseSetFormatWithOptions(config, configModel, nil)
+}
+
+// ParseSetFormatWithOptions is like ParseSetFormat, but takes a
+// ParseOptions to control parsing.  In lenient mode, lines with
+// errors are skipped and parsing continues with the next line.
+func ParseSetFormatWithOptions(config string, configModel *configmodel.VyOSConfigNode, options *ParseOptions) (*VyOSConfigAST, error) {
         ast := &VyOSConfigAST{}
         child := &Node{
                 Type: "root",
         }
         ast.Child = child
+        ast.ConfigModel = configModel
         scanner := bufio.NewScanner(strings.NewReader(config))
+        ps := newParseState(options)
 
         if err := scanner.Err(); err != nil {
                 return ast, fmt.Errorf("Error occurred while scanning: %v", err)
         }
 
-        lineno := 0
         for scanner.Scan() {
-                line := strings.TrimSpace(scanner.Text())
-                lineno++
+                ps.lineno++
 
-                fields, err := shellquote.Split(line)
+                fields, columns, err := splitSetLine(scanner.Text(), ps.lineno)
+                if err == nil {
+                        err = parseSetLine(ast, fields, columns, configModel, ps.lineno)
+                }
                 if err != nil {
-                        return nil, fmt.Errorf("Failed to split %q by words at line %d: %v", line, lineno, err)
+                        if err := ps.report(err); err != nil {
+                                return nil, err // lineno should be in err already
+                        }
                 }
+        }
 
-                err = parseSetLine(ast, fields, configModel, lineno)
-                if err != nil {
-                        return nil, err // lineno should be in err already
+        return ast, ps.err()
+}
+
+// splitSetLine splits a line into shell-quoted words, returning the
+// words and the column that each one started at.
+func splitSetLine(line string, lineno int) ([]string, []int, error) {
+        fields, err := shellquote.Split(strings.TrimSpace(line))
+        if err != nil {
+                return nil, nil, newParseError(lineno, 0, nil, fmt.Sprintf("Failed to split %q by words: %v", strings.TrimSpace(line), err))
+        }
+        return fields, fieldColumns(line), nil
+}
+
+// fieldColumns returns the 1-based column where each shell word in
+// `line` starts.  It follows the same quoting rules as shellquote, so
+// the results line up with shellquote.Split.
+func fieldColumns(line string) []int {
+        columns := []int{}
+        inWord := false
+        escaped := false
+        var quote rune
+
+        for i, r := range line {
+                if !inWord && unicode.IsSpace(r) {
+                        continue
+                }
+                if !inWord {
+                        inWord = true
+                        columns = append(columns, i+1)
+                }
+
+                switch {
+                case escaped:
+                        escaped = false
+                case quote == '\'':
+                        if r == '\'' {
+                                quote = 0
+                        }
+                case quote == '"':
+                        if r == '"' {
+                                quote = 0
+                        } else if r == '\\' {
+                                escaped = true
+                        }
+                case r == '\\':
+                        escaped = true
+                case r == '\'' || r == '"':
+                        quote = r
+                case unicode.IsSpace(r):
+                        inWord = false
                 }
         }
+        return columns
+}
 
-        return ast, nil
+// columnOf returns the column of field `i`, or 0 if it isn't known.
+func columnOf(columns []int, i int) int {
+        if i >= 0 && i < len(columns) {
+                return columns[i]
+        }
+        return 0
 }
 
 // Unquote removes quotes from a string; for double quotes
 // strconv.Unquote would work, but that doesn't work for single
 // quotes.
 func unquote(s string) string {
+        if len(s) < 2 {
+                return s
+        }
+
         // For double quotes, use strconv.Unquote
         if s[0] == '"' {
                 u, err := strconv.Unquote(s)
@@ -101,64 +175,310 @@ func unquote(s string) string {
         return s
 }
 
-func parseSetLine(ast *VyOSConfigAST, fields []string, configModel *configmodel.VyOSConfigNode, lineno int) error {
-        // Allow blank lines and shell or C++ comments
-        if len(fields) == 0 || len(fields[0]) == 0 || fields[0][0] == '#' || fields[0][0:2] == "//" {
+// SetCommand is a single command from a `set`-format change script.
+// Scripts that are pushed to routers usually mix `set` and `delete`
+// commands, and the order that they're applied in matters, so
+// ParseSetCommands returns them as a list instead of applying them
+// to an AST immediately.
+type SetCommand struct {
+        Op      string         // SetOpSet, SetOpDelete, or SetOpComment
+        Path    []*PathElement // The config path that Op applies to
+        Comment string         // The comment text, for SetOpComment
+        Lineno  int            // The line number that this came from, if any
+}
+
+const (
+        SetOpSet     = "set"
+        SetOpDelete  = "delete"
+        SetOpComment = "comment"
+)
+
+// PathElement is a single step along a config path.  For `set
+// interfaces ethernet eth0 address dhcp`, the path has three
+// elements: `interfaces` (with no value), `ethernet` (with a value of
+// `eth0`), and `address` (with a value of `dhcp`).
+type PathElement struct {
+        ContextNode *configmodel.VyOSConfigNode
+        Value       *string
+}
+
+// String returns the command in the same format that `show |
+// commands` would use.
+func (sc *SetCommand) String() string {
+        words := []string{sc.Op}
+        for _, pe := range sc.Path {
+                words = append(words, pe.ContextNode.Name)
+                if pe.Value != nil {
+                        words = append(words, quoteSetValue(pe.ContextNode.Type, *pe.Value))
+                }
+        }
+        if sc.Op == SetOpComment {
+                words = append(words, quoteComment(sc.Comment))
+        }
+        return strings.Join(words, " ")
+}
+
+// quoteSetValue quotes a value the same way that VyOS does for `set`
+// commands.
+func quoteSetValue(nodeType, value string) string {
+        if nodeType == "leafnode" {
+                // VyOS always single-quotes LeafNode values
+                return "'" + value + "'"
+        }
+        // VyOS presumably still quotes TagNode values if they
+        // have spaces and/or shell metacharacters.
+        return quoteIfNeeded(value)
+}
+
+// quoteComment quotes the text of a `comment` command.  Comments can
+// span multiple lines, but `set` format is strictly one command per
+// line, so newlines are written as `\n`, and backslashes as `\\` so
+// that a literal `\n` survives.  Single quotes use the usual shell
+// `'\''` trick, so the result can be split with shellquote.
+func quoteComment(comment string) string {
+        comment = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(comment)
+        comment = strings.ReplaceAll(comment, "'", "'\\''")
+        return "'" + comment + "'"
+}
+
+// unescapeComment undoes the escaping done by quoteComment.  Any
+// other backslash is left alone.
+func unescapeComment(comment string) string {
+        var b strings.Builder
+        for i := 0; i < len(comment); i++ {
+                if comment[i] == '\\' && i+1 < len(comment) {
+                        switch comment[i+1] {
+                        case 'n':
+                                b.WriteByte('\n')
+                                i++
+                                continue
+                        case '\\':
+                                b.WriteByte('\\')
+                                i++
+                                continue
+                        }
+                }
+                b.WriteByte(comment[i])
+        }
+        return b.String()
+}
+
+// ParseSetCommands takes a VyOS change script made up of `set`,
+// `delete`, and `comment` commands and returns the commands in order,
+// without applying them.  Each command's path is checked against
+// `configModel`, so an invalid path is reported here rather than when
+// the commands are applied.
+func ParseSetCommands(config string, configModel *configmodel.VyOSConfigNode) ([]*SetCommand, error) {
+        commands := []*SetCommand{}
+        scanner := bufio.NewScanner(strings.NewReader(config))
+
+        lineno := 0
+        for scanner.Scan() {
+                lineno++
+
+                fields, columns, err := splitSetLine(scanner.Text(), lineno)
+                if err != nil {
+                        return nil, err
+                }
+
+                command, err := parseSetCommand(fields, columns, configModel, lineno)
+                if err != nil {
+                        return nil, err
+                }
+                if command != nil {
+                        commands = append(commands, command)
+                }
+        }
+
+        if err := scanner.Err(); err != nil {
+                return nil, fmt.Errorf("Error occurred while scanning: %v", err)
+        }
+
+        return commands, nil
+}
+
+// ApplySetCommands applies a list of commands to the AST, in order.
+// This is the same as pasting them into a `configure` session on the
+// router, except that there's no `commit` step; each command takes
+// effect immediately.
+func (vca *VyOSConfigAST) ApplySetCommands(commands []*SetCommand) error {
+        for _, command := range commands {
+                if err := vca.applySetCommand(command); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+func (vca *VyOSConfigAST) applySetCommand(command *SetCommand) error {
+        switch command.Op {
+        case SetOpSet:
+                node := vca.Child
+                for _, pe := range command.Path {
+                        node = node.addNode(pe.ContextNode, pe.Value)
+                }
+                return nil
+        case SetOpDelete:
+                if !vca.Child.deletePath(command.Path) {
+                        return newParseError(command.Lineno, 0, pathWords(command.Path), "Nothing to delete (the specified node does not exist)")
+                }
                 return nil
+        case SetOpComment:
+                node := vca.Child.findPath(command.Path)
+                if node == nil {
+                        return newParseError(command.Lineno, 0, pathWords(command.Path), "Cannot add comment (the specified node does not exist)")
+                }
+                node.Comment = command.Comment
+                return nil
+        }
+        return newParseError(command.Lineno, 0, nil, fmt.Sprintf("Unknown command %q", command.Op))
+}
+
+// parseSetCommand turns a single line of words into a SetCommand.
+// Blank lines and comments return a nil SetCommand and a nil error.
+func parseSetCommand(fields []string, columns []int, configModel *configmodel.VyOSConfigNode, lineno int) (*SetCommand, error) {
+        // Allow blank lines and shell or C++ comments
+        if len(fields) == 0 || len(fields[0]) == 0 || fields[0][0] == '#' || strings.HasPrefix(fields[0], "//") {
+                return nil, nil
+        }
+
+        op := fields[0]
+        if op == SetOpComment {
+                return parseCommentCommand(fields, columns, configModel, lineno)
+        }
+        if op != SetOpSet && op != SetOpDelete {
+                pe := newParseError(lineno, columnOf(columns, 0), nil, "First word is not 'set', 'delete', or 'comment'")
+                pe.Token = op
+                pe.Allowed = []string{SetOpSet, SetOpDelete, SetOpComment}
+                pe.Suggestions = suggest(op, pe.Allowed)
+                return nil, pe
+        }
+
+        // `delete` is allowed to stop short of the final value, in
+        // order to remove every value of a TagNode or LeafNode.
+        path, err := parsePath(fields[1:], columns[min(1, len(columns)):], configModel, op == SetOpDelete, lineno)
+        if err != nil {
+                return nil, err
+        }
+
+        return &SetCommand{
+                Op:     op,
+                Path:   path,
+                Lineno: lineno,
+        }, nil
+}
+
+// parseCommentCommand parses `comment <path> '<text>'`.  LeafNode
+// values are optional in the path, because VyOS attaches comments to
+// the leaf itself, but TagNodes need a value to say which entry the
+// comment belongs to.
+func parseCommentCommand(fields []string, columns []int, configModel *configmodel.VyOSConfigNode, lineno int) (*SetCommand, error) {
+        if len(fields) < 3 {
+                return nil, newParseError(lineno, 0, nil, "Comment is missing a path or text")
         }
 
-        if fields[0] != "set" {
-                return fmt.Errorf("First word is not 'set' at line %d", lineno)
+        path, err := parsePath(fields[1:len(fields)-1], columns[min(1, len(columns)):], configModel, true, lineno)
+        if err != nil {
+                return nil, err
+        }
+        last := path[len(path)-1]
+        if last.ContextNode.Type == "tagnode" && last.Value == nil {
+                pe := newParseError(lineno, columnOf(columns, len(fields)-1), pathWords(path), fmt.Sprintf("Missing value for %q", last.ContextNode.Name))
+                pe.Token = fields[len(fields)-1]
+                return nil, pe
         }
 
-        i := 1
-        length := len(fields)
+        return &SetCommand{
+                Op:      SetOpComment,
+                Path:    path,
+                Comment: unescapeComment(unquote(fields[len(fields)-1])),
+                Lineno:  lineno,
+        }, nil
+}
+
+// parsePath walks through `fields` and the config model in parallel,
+// returning the path that the words describe.  If `allowMissingValue`
+// is true, then the final element on the path is allowed to be
+// missing its value.  `columns` holds the column of each field, for
+// error reporting, and may be nil.
+func parsePath(fields []string, columns []int, configModel *configmodel.VyOSConfigNode, allowMissingValue bool, lineno int) ([]*PathElement, error) {
+        path := []*PathElement{}
         configNode := configModel
-        node := ast.Child
         errorPath := []string{}
 
-        for {
-                var value *string
-                if i >= length {
-                        break
-                }
+        if len(fields) == 0 {
+                return nil, newParseError(lineno, 0, nil, "Missing config path")
+        }
+
+        for i := 0; i < len(fields); i++ {
                 field := unquote(fields[i])
                 newConfigNode := configNode.FindNodeByName(field)
                 if newConfigNode == nil {
-                        options := []string{}
-                        for _, n := range configNode.Children {
-                                options = append(options, n.Name)
-                        }
-                        return fmt.Errorf("Unexpected word %q at line %d (options for %q are %v)", field, lineno, strings.Join(errorPath, " > "), options)
+                        return nil, newUnknownWordError(lineno, columnOf(columns, i), errorPath, field, configNode)
                 }
 
                 configNode = newConfigNode
+                pe := &PathElement{ContextNode: configNode}
 
                 if configNode.HasValue {
-                        i++
-                        v := unquote(fields[i])
-                        value = &v
-                        errorPath = append(errorPath, field+" "+v)
+                        if i+1 < len(fields) {
+                                i++
+                                v := unquote(fields[i])
+                                pe.Value = &v
+                                errorPath = append(errorPath, field, v)
+                        } else if !allowMissingValue {
+                                return nil, newParseError(lineno, columnOf(columns, i), errorPath, fmt.Sprintf("Missing value for %q", field))
+                        }
                 } else {
                         errorPath = append(errorPath, field)
                 }
 
-                newnode := node.addNode(configNode, value)
-                newnode.Value = value
-                node = newnode
+                path = append(path, pe)
+        }
+
+        return path, nil
+}
 
-                i++
+// pathWords returns the words that make up `path`, without any
+// quoting.
+func pathWords(path []*PathElement) []string {
+        words := []string{}
+        for _, pe := range path {
+                words = append(words, pe.ContextNode.Name)
+                if pe.Value != nil {
+                        words = append(words, *pe.Value)
+                }
         }
+        return words
+}
 
-        return nil
+// parseSetLine parses a single `set`, `delete`, or `comment` line and applies it
+// to the AST.
+func parseSetLine(ast *VyOSConfigAST, fields []string, columns []int, configModel *configmodel.VyOSConfigNode, lineno int) error {
+        command, err := parseSetCommand(fields, columns, configModel, lineno)
+        if err != nil || command == nil {
+                return err
+        }
+        return ast.applySetCommand(command)
 }
 
 // WriteSetFormat returns a string that contains the `set` format of
 // the specified config AST.  That is, it writes out a bunch of `set
 // ...` command lines that can be copied into VyOS to tell it to
-// configure itself a specific way.
+// configure itself a specific way.  Comments attached to nodes are
+// written as `comment ...` lines after the node's `set` lines.
 func WriteSetFormat(ast *VyOSConfigAST) (string, error) {
-        results, err := writeSetPartial(ast.Child, "set")
+        return WriteSetFormatWithOptions(ast, nil)
+}
+
+// WriteSetFormatWithOptions is like WriteSetFormat, but takes a
+// WriteOptions to control the output.
+func WriteSetFormatWithOptions(ast *VyOSConfigAST, options *WriteOptions) (string, error) {
+        ast, err := options.prepare(ast)
+        if err != nil {
+                return "", err
+        }
+        results, err := writeSetPartial(ast.Child, "set", "comment")
         if err != nil {
                 return "", err
         }
@@ -166,33 +486,36 @@ func WriteSetFormat(ast *VyOSConfigAST) (string, error) {
 }
 
 // writeSetPartial recursively turns AST nodes into `set ...` strings.
-func writeSetPartial(node *Node, context string) ([]string, error) {
+// `commentContext` tracks the path for `comment ...` lines, which
+// differs from `context` because it leaves out LeafNode values.
+func writeSetPartial(node *Node, context, commentContext string) ([]string, error) {
         results := []string{}
         if node.ContextNode != nil {
                 context = context + " " + node.ContextNode.Name
+                commentContext = commentContext + " " + node.ContextNode.Name
         }
 
         if node.Value != nil {
-                if node.Type == "leafnode" {
-                        // VyOS always single-quotes LeafNode values
-                        context = context + " '" + *node.Value + "'"
-                } else {
-                        // VyOS presumably still quotes TagNode values
-                        // if they have spaces and/or shell
-                        // metacharacters.
-                        context = context + " " + quoteIfNeeded(*node.Value)
+                context = context + " " + quoteSetValue(node.Type, *node.Value)
+                if node.Type != "leafnode" {
+                        commentContext = commentContext + " " + quoteSetValue(node.Type, *node.Value)
                 }
         }
 
         for _, child := range node.Children {
-                childresults, err := writeSetPartial(child, context)
+                childresults, err := writeSetPartial(child, context, commentContext)
                 if err != nil {
                         return nil, err
                 }
                 results = append(results, childresults...)
         }
-        if len(node.Children) == 0 {
+        // An empty root node would otherwise come out as a bare
+        // `set` line.
+        if len(node.Children) == 0 && node.ContextNode != nil {
                 results = append(results, context)
         }
+        if node.Comment != "" {
+                results = append(results, commentContext+" "+quoteComment(node.Comment))
+        }
         return results, nil
 }
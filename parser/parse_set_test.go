@@ -20,6 +20,18 @@ func getConfigModel(t *testing.T) *configmodel.VyOSConfigNode {
         return configModel
 }
 
+// getConfigNode returns the node at `path` in `configModel`.
+func getConfigNode(t *testing.T, configModel *configmodel.VyOSConfigNode, path ...string) *configmodel.VyOSConfigNode {
+        n := configModel
+        for _, name := range path {
+                n = n.FindNodeByName(name)
+                if n == nil {
+                        t.Fatalf("No %q under %v in the config model", name, path)
+                }
+        }
+        return n
+}
+
 func TestParseSetComment(t *testing.T) {
         configModel := getConfigModel(t)
 
@@ -37,6 +49,25 @@ func TestParseSetComment(t *testing.T) {
         if err == nil {
                 t.Errorf("Failed to error on C-style comment: %v", err)
         }
+
+        // Newlines and backslashes both survive a round trip.
+        config := `set system host-name 'router1'
+comment system host-name 'C:\\new\nline'
+`
+        ast, err := ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse comment: %v", err)
+        }
+        if want := `C:\new` + "\nline"; ast.Child.Children[0].Children[0].Comment != want {
+                t.Errorf("Got comment %q, want %q", ast.Child.Children[0].Children[0].Comment, want)
+        }
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        if set != config {
+                t.Errorf("Got %q after round-trip, want %q", set, config)
+        }
 }
 
 func TestParseSetRoundTrip(t *testing.T) {
@@ -126,6 +157,17 @@ set interfaces ethernet eth0 address 'dhcp'
 
 }
 
+func TestWriteSetFormatEmpty(t *testing.T) {
+        ast := &VyOSConfigAST{Child: &Node{Type: "root"}}
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        if strings.TrimSpace(set) != "" {
+                t.Errorf("Got %q from an empty AST, want no set lines", set)
+        }
+}
+
 func TestParseSetNAT(t *testing.T) {
         configModel := getConfigModel(t)
         showConfig := ` nat {
@@ -168,3 +210,117 @@ set nat source rule 100 translation address 'masquerade'
 
         }
 }
+
+func TestParseSetDelete(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `set firewall flowtable default interface eth0
+set firewall flowtable default interface eth1
+set firewall flowtable default offload software
+set interfaces ethernet eth0 address 'dhcp'
+set interfaces ethernet eth0 description 'uplink'
+set interfaces ethernet eth1 address '10.0.0.1/24'
+set system host-name 'router1'
+delete firewall flowtable default interface eth0
+delete interfaces ethernet eth0 description
+delete interfaces ethernet eth1
+delete system
+`
+        ast, err := ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse static config: %v", err)
+        }
+
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+
+        expected := `set firewall flowtable default interface 'eth1'
+set firewall flowtable default offload 'software'
+set interfaces ethernet eth0 address 'dhcp'
+`
+        if set != expected {
+                edits := myers.ComputeEdits("foo", expected, set)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", expected, edits))
+                t.Errorf("Generated set-format file does not match expected")
+        }
+}
+
+func TestParseSetDeleteMissing(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        tests := []string{
+                "delete system host-name",
+                "set system host-name 'router1'\ndelete system host-name 'router2'",
+                "set interfaces ethernet eth0 address 'dhcp'\ndelete interfaces ethernet eth1",
+        }
+
+        for _, test := range tests {
+                _, err := ParseSetFormat(test, configModel)
+                if err == nil {
+                        t.Errorf("Expected error when deleting a missing node from %q", test)
+                }
+        }
+}
+
+func TestParseSetCommands(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `# change script
+set interfaces ethernet eth0 description 'new uplink'
+delete interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+delete protocols static
+`
+        commands, err := ParseSetCommands(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse change script: %v", err)
+        }
+
+        want := []string{
+                "set interfaces ethernet eth0 description 'new uplink'",
+                "delete interfaces ethernet eth0 address '10.0.0.1/24'",
+                "set interfaces ethernet eth0 address '10.0.0.2/24'",
+                "delete protocols static",
+        }
+        if len(commands) != len(want) {
+                t.Fatalf("Got %d commands, want %d", len(commands), len(want))
+        }
+        for i, command := range commands {
+                if command.String() != want[i] {
+                        t.Errorf("Command %d: got %q, want %q", i, command.String(), want[i])
+                }
+        }
+        if commands[1].Lineno != 3 {
+                t.Errorf("Got Lineno=%d for command 1, want 3", commands[1].Lineno)
+        }
+
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set protocols static route 0.0.0.0/0 next-hop 10.0.0.254
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse static config: %v", err)
+        }
+
+        err = ast.ApplySetCommands(commands)
+        if err != nil {
+                t.Fatalf("Failed to apply change script: %v", err)
+        }
+
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        expected := `set interfaces ethernet eth0 description 'new uplink'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+`
+        if set != expected {
+                edits := myers.ComputeEdits("foo", expected, set)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", expected, edits))
+                t.Errorf("Generated set-format file does not match expected")
+        }
+
+        _, err = ParseSetCommands("set interfaces ethernet", configModel)
+        if err == nil {
+                t.Errorf("Expected error for `set` with a missing tag value")
+        }
+}
@@ -26,71 +26,112 @@ func doubleQuoteIfNeeded(value string) string {
 // returned by 'show' from config mode and returns a VyOSConfigAST
 // and/or an error.
 func ParseShowFormat(config string, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, error) {
+        return ParseShowFormatWithOptions(config, configModel, nil)
+}
+
+// ParseShowFormatWithOptions is like ParseShowFormat, but takes a
+// ParseOptions to control parsing.
+func ParseShowFormatWithOptions(config string, configModel *configmodel.VyOSConfigNode, options *ParseOptions) (*VyOSConfigAST, error) {
         ast := &VyOSConfigAST{}
         child := &Node{
                 Type: "root",
         }
         ast.Child = child
+        ast.ConfigModel = configModel
         scanner := bufio.NewScanner(strings.NewReader(config))
+        ps := newParseState(options)
 
         if err := scanner.Err(); err != nil {
                 return ast, fmt.Errorf("Error occurred while scanning: %v", err)
         }
 
-        lineno := 0
-        err := parseShowFormat(child, scanner, configModel, &lineno)
-        return ast, err
+        if err := parseVersionFooter(ast, config); err != nil {
+                if err := ps.report(err); err != nil {
+                        return ast, err
+                }
+        }
+
+        if err := parseShowFormat(child, scanner, configModel, ps, []string{}); err != nil {
+                return ast, err
+        }
+        return ast, ps.err()
 }
 
 // parseShowFormat parses everything underneath a higher-level
 // node in the config.  It reads from the `config` scanner and updates
 // `nodeContext` as needed, returning an error if it's unable to parse.
-func parseShowFormat(nodeContext *Node, scanner *bufio.Scanner, configModel *configmodel.VyOSConfigNode, lineno *int) error {
+// `path` holds the words leading up to `nodeContext`, for error
+// reporting.  Running out of text before the closing `}` of a block
+// is an error.
+func parseShowFormat(nodeContext *Node, scanner *bufio.Scanner, configModel *configmodel.VyOSConfigNode, ps *parseState, path []string) error {
+        // Comments appear on the line(s) before the node that
+        // they're attached to.
+        comment := ""
+
+        // Remember where this block started, for reporting
+        // unclosed blocks.
+        openLine, openColumn := ps.lineno, ps.column
+
         for scanner.Scan() {
                 line := scanner.Text()
-                (*lineno)++
+                ps.lineno++
+                column := len(line) - len(strings.TrimLeft(line, " \t")) + 1
+                ps.column = column
                 
                 line = strings.TrimSpace(line)
 
-                if len(line)==0 || line[0]=='/' {
+                if len(line)==0 || strings.HasPrefix(line, "//") {
+                        continue
+                }
+
+                if strings.HasPrefix(line, "/*") {
+                        text, err := readComment(line, column, scanner, &ps.lineno)
+                        if err != nil {
+                                return ps.report(err)
+                        }
+                        comment = text
                         continue
                 }
                 
                 if line[0] == '}' {
-                        return nil
+                        done, err := closeBlock(line, column, path, ps)
+                        if err != nil {
+                                return err
+                        }
+                        if done {
+                                return nil
+                        }
+                        continue
                 }
 
                 name := strings.SplitN(line, " ", 2)[0]
+                opensBlock := line[len(line)-1] == '{'
 
                 configNode := configModel.FindNodeByName(name)
                 if configNode == nil {
-                        return fmt.Errorf("Couldn't match config token %q at line number %d", name, *lineno)
+                        if err := ps.report(newUnknownWordError(ps.lineno, column, path, name, configModel)); err != nil {
+                                return err
+                        }
+                        if opensBlock {
+                                ps.skipBlock(scanner)
+                        }
+                        continue
                 }
 
                 // Figure out if there's a value associated with this node
-                remainingLine := line[len(name):]
-                value := ""
-                if len(remainingLine)>0 {
-                        if remainingLine[len(remainingLine)-1] == '{' {
-                                remainingLine = remainingLine[:len(remainingLine)-1] // strip "{" and whitespace from the end.
+                v, err := parseLineValue(line, name, column, path, configNode, ps.lineno)
+                if err != nil {
+                        if err := ps.report(err); err != nil {
+                                return err
                         }
-                        remainingLine = strings.TrimSpace(remainingLine)
-
-                        // Deal with quoting
-                        if len(remainingLine)>0 && remainingLine[0]=='"' {
-                                val, err := strconv.Unquote(remainingLine)
-                                if err != nil {
-                                        fmt.Printf("Unquote error: %v\n", err)
-                                        return err
-                                }
-                                value = val
-                        } else {
-                                if len(remainingLine)>0 {
-                                        // Not completely happy about this
-                                        value = remainingLine
-                                        //fmt.Printf("Found extra value of %q in %q at line %d\n", remainingLine, line, *lineno)
-                                }
+                        if opensBlock {
+                                ps.skipBlock(scanner)
                         }
+                        continue
+                }
+                value := ""
+                if v != nil {
+                        value = *v
                 }
 
                 var astNode *Node
@@ -121,17 +162,38 @@ func parseShowFormat(nodeContext *Node, scanner *bufio.Scanner, configModel *con
                 if value != "" {
                         astNode.Value = &value
                 }
+
+                if comment != "" {
+                        astNode.Comment = comment
+                        comment = ""
+                }
                 
-                if line[len(line)-1] == '{' {
-                        parseShowFormat(astNode, scanner, configNode, lineno)
+                if opensBlock {
+                        childPath := append(path, name)
+                        if astNode.Value != nil {
+                                childPath = append(childPath, *astNode.Value)
+                        }
+                        if err := parseShowFormat(astNode, scanner, configNode, ps, childPath); err != nil {
+                                return err
+                        }
                 }
         }
 
-        // Ran out of text?
-        return nil
+        // Ran out of text.
+        return ps.checkTruncated(path, openLine, openColumn)
 }
 
 func WriteShowFormat(ast *VyOSConfigAST) (string, error) {
+        return WriteShowFormatWithOptions(ast, nil)
+}
+
+// WriteShowFormatWithOptions is like WriteShowFormat, but takes a
+// WriteOptions to control the output.
+func WriteShowFormatWithOptions(ast *VyOSConfigAST, options *WriteOptions) (string, error) {
+        ast, err := options.prepare(ast)
+        if err != nil {
+                return "", err
+        }
         results, err := writeShowPartial(ast.Child, 1)
         if err != nil {
                 return "", err
@@ -139,23 +201,32 @@ func WriteShowFormat(ast *VyOSConfigAST) (string, error) {
         return strings.Join(results, "\n")+"\n", nil
 }
 
+// showLine returns the name and value of `node` as they appear in
+// `show` format, without indenting or braces.
+func showLine(node *Node) string {
+        line := node.ContextNode.Name
+        if node.Value != nil && *node.Value != "" {
+                line = line + " " + doubleQuoteIfNeeded(*node.Value)
+        }
+        return line
+}
+
 func writeShowPartial(node *Node, indent int) ([]string, error) {
         results := []string{}
         line := ""
         newIndent := indent + 4
 
         if node.ContextNode != nil {
-                line = node.ContextNode.Name
-                
-                if node.Value != nil && *node.Value != "" {
-                        value := *node.Value
-                        line = line + " " + doubleQuoteIfNeeded(value)
-                }
+                line = showLine(node)
         } else {
                 newIndent = indent
         }
         
 
+        if node.Comment != "" && line != "" {
+                results = append(results, writeComment(node.Comment, indent))
+        }
+
         if node.Type == "leafnode" {
                 results = append(results, spaces(indent) + line)
         } else {
@@ -0,0 +1,101 @@
+package parser
+
+import (
+        "os"
+        "strings"
+        "testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+        tests := []struct {
+                name   string
+                config string
+                want   Format
+        }{
+                {"set", "set system host-name 'router1'\n", FormatSet},
+                {"delete", "# change\ndelete system host-name\n", FormatSet},
+                {"show", "system {\n    host-name router1\n}\n", FormatShow},
+                {"config.boot", "system {\n    host-name \"router1\"\n}\n", FormatConfigBoot},
+                {"show with quoting", "system {\n    login {\n        banner {\n            pre-login \"hello world\"\n        }\n    }\n}\n", FormatConfigBoot},
+                {"show indented", " system {\n     ipv6 {\n     }\n }\n", FormatShow},
+                {"footer", "system {\n    ipv6 {\n    }\n}\n// Release version: 1.4.1\n", FormatConfigBoot},
+                {"empty", "\n// nothing here\n", FormatUnknown},
+        }
+
+        for _, test := range tests {
+                got := DetectFormat(test.config)
+                if got != test.want {
+                        t.Errorf("%s: DetectFormat() = %v, want %v", test.name, got, test.want)
+                }
+        }
+}
+
+func TestParseDetected(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        tests := []struct {
+                filename string
+                want     Format
+        }{
+                {"testdata/config.set.1", FormatSet},
+                {"testdata/config.show.1", FormatShow},
+                {"testdata/config.boot.1", FormatConfigBoot},
+        }
+
+        for _, test := range tests {
+                f, err := os.Open(test.filename)
+                if err != nil {
+                        t.Fatalf("Failed to open testdata file %s: %v", test.filename, err)
+                }
+                defer f.Close()
+
+                ast, format, err := ParseReader(f, configModel)
+                if err != nil {
+                        t.Fatalf("Failed to parse %s: %v", test.filename, err)
+                }
+                if format != test.want {
+                        t.Errorf("%s: got format %v, want %v", test.filename, format, test.want)
+                }
+
+                treesize := ast.TreeSize()
+                want := 162
+                if treesize != want {
+                        t.Errorf("%s: got treesize=%d, want %d", test.filename, treesize, want)
+                }
+        }
+}
+
+func TestParseEmpty(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        ast, format, err := Parse("", configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse empty config: %v", err)
+        }
+        if format != FormatUnknown {
+                t.Errorf("Got format %v, want %v", format, FormatUnknown)
+        }
+        set, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        if strings.TrimSpace(set) != "" {
+                t.Errorf("Got %q from empty config, want nothing", set)
+        }
+}
+
+func TestDetectFormatJSON(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `{"system": {"host-name": "router1"}}`
+
+        ast, format, err := Parse(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse JSON config: %v", err)
+        }
+        if format != FormatJSON {
+                t.Errorf("Got format %v, want %v", format, FormatJSON)
+        }
+        if ast.TreeSize() != 3 {
+                t.Errorf("Got treesize=%d, want 3", ast.TreeSize())
+        }
+}
@@ -0,0 +1,206 @@
+package parser
+
+import (
+        "fmt"
+        "strconv"
+        "strings"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+        "gopkg.in/yaml.v3"
+)
+
+// The YAML format uses the same structure as the JSON format (see
+// parse_json.go), so a config looks like this:
+//
+//      interfaces:
+//          ethernet:
+//              eth0:
+//                  address:
+//                      - 10.0.0.1/24
+//                  # Uplink to the core switch
+//                  description: uplink
+//                  disable-flow-control: {}
+//
+// Everything is read back with the help of the config model, so
+// scalars are written without quotes whenever YAML allows it.  Node
+// comments are written as YAML comments above the node.
+
+// ParseYAMLFormat takes a VyOS configuration in YAML format and
+// returns a VyOSConfigAST and/or an error.  The structure of the YAML
+// is checked against `configModel`.
+func ParseYAMLFormat(config string, configModel *configmodel.VyOSConfigNode) (*VyOSConfigAST, error) {
+        return ParseYAMLFormatWithOptions(config, configModel, nil)
+}
+
+// ParseYAMLFormatWithOptions is like ParseYAMLFormat, but takes a
+// ParseOptions to control parsing.
+func ParseYAMLFormatWithOptions(config string, configModel *configmodel.VyOSConfigNode, options *ParseOptions) (*VyOSConfigAST, error) {
+        ast := &VyOSConfigAST{}
+        child := &Node{
+                Type: "root",
+        }
+        ast.Child = child
+        ast.ConfigModel = configModel
+        ps := newParseState(options)
+
+        doc := &yaml.Node{}
+        if err := yaml.Unmarshal([]byte(config), doc); err != nil {
+                return ast, fmt.Errorf("Failed to decode YAML: %v", err)
+        }
+        if doc.Kind == 0 {
+                // Empty document
+                return ast, nil
+        }
+
+        root := doc
+        if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+                root = doc.Content[0]
+        }
+
+        value, err := decodeYAMLValue(root)
+        if err != nil {
+                if err := ps.report(newParseError(root.Line, root.Column, nil, err.Error())); err != nil {
+                        return ast, err
+                }
+                return ast, ps.err()
+        }
+        obj, ok := value.(*jsonObject)
+        if !ok {
+                if err := ps.report(newParseError(root.Line, root.Column, nil, "Top level of YAML config is not a mapping")); err != nil {
+                        return ast, err
+                }
+                return ast, ps.err()
+        }
+
+        if err := parseJSONObject(child, obj, configModel, ps, []string{}); err != nil {
+                return ast, err
+        }
+        return ast, ps.err()
+}
+
+// decodeYAMLValue turns a yaml.Node into the same tree of values that
+// decodeJSONValue produces.  Null and boolean scalars are returned as
+// nil and bool, for valueless LeafNodes; every other scalar is
+// returned as its original text.
+func decodeYAMLValue(node *yaml.Node) (any, error) {
+        return decodeYAMLNode(node, map[*yaml.Node]bool{})
+}
+
+// decodeYAMLNode implements decodeYAMLValue.  `aliases` holds the
+// anchored nodes currently being decoded, so that an alias that
+// refers back to its own anchor is an error instead of infinite
+// recursion.
+func decodeYAMLNode(node *yaml.Node, aliases map[*yaml.Node]bool) (any, error) {
+        switch node.Kind {
+        case yaml.MappingNode:
+                obj := &jsonObject{}
+                for i := 0; i+1 < len(node.Content); i += 2 {
+                        key, value := node.Content[i], node.Content[i+1]
+                        v, err := decodeYAMLNode(value, aliases)
+                        if err != nil {
+                                return nil, err
+                        }
+                        obj.Members = append(obj.Members, &jsonMember{
+                                Key:     key.Value,
+                                Value:   v,
+                                Line:    key.Line,
+                                Comment: yamlCommentText(key.HeadComment),
+                        })
+                }
+                return obj, nil
+        case yaml.SequenceNode:
+                list := []any{}
+                for _, item := range node.Content {
+                        v, err := decodeYAMLNode(item, aliases)
+                        if err != nil {
+                                return nil, err
+                        }
+                        list = append(list, v)
+                }
+                return list, nil
+        case yaml.ScalarNode:
+                switch node.ShortTag() {
+                case "!!null":
+                        return nil, nil
+                case "!!bool":
+                        b, err := strconv.ParseBool(strings.ToLower(node.Value))
+                        if err == nil {
+                                return b, nil
+                        }
+                }
+                return node.Value, nil
+        case yaml.AliasNode:
+                if aliases[node.Alias] {
+                        return nil, fmt.Errorf("YAML alias %q at line %d refers to itself", node.Value, node.Line)
+                }
+                aliases[node.Alias] = true
+                defer delete(aliases, node.Alias)
+                return decodeYAMLNode(node.Alias, aliases)
+        }
+        return nil, fmt.Errorf("Unexpected YAML node at line %d", node.Line)
+}
+
+// yamlCommentText strips the `#` markers from a YAML comment.
+func yamlCommentText(comment string) string {
+        if comment == "" {
+                return ""
+        }
+        lines := []string{}
+        for _, line := range strings.Split(comment, "\n") {
+                line = strings.TrimSpace(line)
+                line = strings.TrimPrefix(line, "#")
+                lines = append(lines, strings.TrimPrefix(line, " "))
+        }
+        return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// WriteYAMLFormat returns the YAML format of the specified config
+// AST.
+func WriteYAMLFormat(ast *VyOSConfigAST) (string, error) {
+        obj, err := writeJSONPartial(ast.Child)
+        if err != nil {
+                return "", err
+        }
+
+        b, err := yaml.Marshal(encodeYAMLValue(obj))
+        if err != nil {
+                return "", err
+        }
+        return string(b), nil
+}
+
+// encodeYAMLValue turns the output of writeJSONPartial into a
+// yaml.Node, preserving key order and comments.
+func encodeYAMLValue(value any) *yaml.Node {
+        switch v := value.(type) {
+        case *jsonObject:
+                node := &yaml.Node{Kind: yaml.MappingNode}
+                if len(v.Members) == 0 {
+                        node.Style = yaml.FlowStyle // `{}`
+                }
+                for _, m := range v.Members {
+                        key := encodeYAMLValue(m.Key)
+                        if m.Comment != "" {
+                                key.HeadComment = "# " + strings.ReplaceAll(m.Comment, "\n", "\n# ")
+                        }
+                        node.Content = append(node.Content, key, encodeYAMLValue(m.Value))
+                }
+                return node
+        case []string:
+                node := &yaml.Node{Kind: yaml.SequenceNode}
+                for _, s := range v {
+                        node.Content = append(node.Content, encodeYAMLValue(s))
+                }
+                return node
+        case string:
+                node := &yaml.Node{Kind: yaml.ScalarNode, Value: v}
+                // Plain scalars that YAML would read as null or a
+                // boolean need quoting to stay strings.
+                switch strings.ToLower(v) {
+                case "", "~", "null", "true", "false":
+                        node.Tag = "!!str"
+                }
+                return node
+        }
+        return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(value)}
+}
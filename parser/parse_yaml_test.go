@@ -0,0 +1,132 @@
+package parser
+
+import (
+        "errors"
+        "fmt"
+        "os"
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+func TestParseYAMLRoundTrip(t *testing.T) {
+        configModel := getConfigModel(t)
+        filename := "testdata/config.set.1"
+
+        b, err := os.ReadFile(filename)
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        originalSetConfig := string(b)
+
+        ast, err := ParseSetFormat(originalSetConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse %s: %v", filename, err)
+        }
+
+        yamlConfig, err := WriteYAMLFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling WriteYAMLFormat: %v", err)
+        }
+
+        ast2, err := ParseYAMLFormat(yamlConfig, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse generated YAML: %v", err)
+        }
+
+        newSetConfig, err := WriteSetFormat(ast2)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+
+        if newSetConfig != originalSetConfig {
+                edits := myers.ComputeEdits("foo", originalSetConfig, newSetConfig)
+                fmt.Println(gotextdiff.ToUnified(filename, "output", originalSetConfig, edits))
+                t.Errorf("Set-format config does not match after round-trip through YAML")
+        }
+}
+
+func TestWriteYAMLFormat(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth0 description 'true'
+set interfaces ethernet eth0 disable-flow-control
+set interfaces ethernet eth0 mtu '9000'
+comment interfaces ethernet eth0 'Uplink to the core switch'
+set system host-name 'router1'
+`
+        ast, err := ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse static config: %v", err)
+        }
+
+        got, err := WriteYAMLFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed calling WriteYAMLFormat: %v", err)
+        }
+
+        expected := `interfaces:
+    ethernet:
+        # Uplink to the core switch
+        eth0:
+            address:
+                - 10.0.0.1/24
+                - 10.0.0.2/24
+            description: "true"
+            disable-flow-control: {}
+            mtu: 9000
+system:
+    host-name: router1
+`
+        if got != expected {
+                edits := myers.ComputeEdits("foo", expected, got)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", expected, edits))
+                t.Errorf("Generated YAML does not match expected")
+        }
+
+        ast2, err := ParseYAMLFormat(got, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse generated YAML: %v", err)
+        }
+        set, err := WriteSetFormat(ast2)
+        if err != nil {
+                t.Fatalf("Failed calling writeSetFormat: %v", err)
+        }
+        if set != config {
+                edits := myers.ComputeEdits("foo", config, set)
+                fmt.Println(gotextdiff.ToUnified("expected", "output", config, edits))
+                t.Errorf("Set-format config does not match after round-trip through YAML")
+        }
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+        configModel := getConfigModel(t)
+        config := `interfaces:
+    ethernet:
+        eth0:
+            disable-flow-control: true
+            adress: 10.0.0.1/24
+system:
+    host-name: router1
+`
+        _, err := ParseYAMLFormat(config, configModel)
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Got error %v, want *ParseError", err)
+        }
+        if pe.Line != 5 || pe.Token != "adress" {
+                t.Errorf("Got error %v, want unknown word \"adress\" at line 5", pe)
+        }
+
+        // Lenient mode still has to report a bad top level, and
+        // values that can't be decoded.
+        for _, config := range []string{"- 1\n- 2\n", "a: &x [*x]\n"} {
+                _, err := ParseYAMLFormatWithOptions(config, configModel, &ParseOptions{Lenient: true})
+                var pes ParseErrors
+                if !errors.As(err, &pes) || len(pes) != 1 {
+                        t.Errorf("Got error %v from lenient parse of %q, want 1 ParseError", err, config)
+                }
+        }
+}
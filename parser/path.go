@@ -0,0 +1,110 @@
+package parser
+
+import (
+        "fmt"
+)
+
+// Get returns the values of every node at `path`, like
+// `interfaces ethernet eth0 address`.  The path is resolved through
+// the AST's config model, so `ethernet eth0` is understood as a
+// TagNode and its value.  The final value may be left off, to get
+// every value of a multi-value LeafNode or every entry of a TagNode;
+// if it's included, then Get returns it only if it's in the config.
+// Valueless nodes contribute an empty string.  It returns an empty
+// list if nothing matches, and a *ParseError if the path isn't valid.
+//
+// Like all of the path methods, `path` can either be a single string,
+// which is split into words using shell quoting rules just like a
+// `set` command, or the individual words of the path.
+func (vca *VyOSConfigAST) Get(path ...string) ([]string, error) {
+        nodes, err := vca.find(path)
+        if err != nil {
+                return nil, err
+        }
+        values := []string{}
+        for _, n := range nodes {
+                if n.Value != nil {
+                        values = append(values, *n.Value)
+                } else {
+                        values = append(values, "")
+                }
+        }
+        return values, nil
+}
+
+// Exists returns true if anything in the config matches `path`.  See
+// Get for the format of `path`.
+func (vca *VyOSConfigAST) Exists(path ...string) (bool, error) {
+        nodes, err := vca.find(path)
+        return len(nodes) > 0, err
+}
+
+// Set sets a value in the config, like VyOS's `set` command.  See
+// Get for the format of `path`.
+func (vca *VyOSConfigAST) Set(path ...string) error {
+        pes, err := vca.resolvePath(path, false)
+        if err != nil {
+                return err
+        }
+        return vca.applySetCommand(&SetCommand{Op: SetOpSet, Path: pes})
+}
+
+// Delete removes part of the config, like VyOS's `delete` command.
+// See Get for the format of `path`.  It returns an error if nothing
+// matched.
+func (vca *VyOSConfigAST) Delete(path ...string) error {
+        pes, err := vca.resolvePath(path, true)
+        if err != nil {
+                return err
+        }
+        return vca.applySetCommand(&SetCommand{Op: SetOpDelete, Path: pes})
+}
+
+// resolvePath turns a path from Get, Set, etc into a list of
+// PathElements using the AST's config model.
+func (vca *VyOSConfigAST) resolvePath(path []string, allowMissingValue bool) ([]*PathElement, error) {
+        if vca.ConfigModel == nil {
+                return nil, fmt.Errorf("AST doesn't have a ConfigModel")
+        }
+
+        fields := path
+        var columns []int
+        if len(path) == 1 {
+                var err error
+                fields, columns, err = splitSetLine(path[0], 0)
+                if err != nil {
+                        return nil, err
+                }
+        } else {
+                // Individual words aren't quoted, so make sure that
+                // parsePath doesn't try to unquote them.  Its
+                // unquote just strips single quotes off of the
+                // ends, so this is safe for any word.
+                fields = []string{}
+                for _, word := range path {
+                        fields = append(fields, "'"+word+"'")
+                }
+        }
+
+        return parsePath(fields, columns, vca.ConfigModel, allowMissingValue, 0)
+}
+
+// find returns every node in the AST that matches `path`.
+func (vca *VyOSConfigAST) find(path []string) ([]*Node, error) {
+        pes, err := vca.resolvePath(path, true)
+        if err != nil {
+                return nil, err
+        }
+
+        parent := vca.Child.findPath(pes[:len(pes)-1])
+        if parent == nil {
+                return []*Node{}, nil
+        }
+        nodes := []*Node{}
+        for _, child := range parent.Children {
+                if child.matches(pes[len(pes)-1]) {
+                        nodes = append(nodes, child)
+                }
+        }
+        return nodes, nil
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+        "errors"
+        "os"
+        "slices"
+        "testing"
+)
+
+func TestPathGet(t *testing.T) {
+        configModel := getConfigModel(t)
+        b, err := os.ReadFile("testdata/config.set.1")
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        ast, err := ParseSetFormat(string(b), configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        tests := []struct {
+                path []string
+                want []string
+        }{
+                {[]string{"interfaces ethernet eth0 address"}, []string{"10.250.1.254/24"}},
+                {[]string{"system name-server"}, []string{"10.1.5.70", "8.8.8.8", "8.8.4.4"}},
+                {[]string{"system name-server 8.8.8.8"}, []string{"8.8.8.8"}},
+                {[]string{"system name-server 1.1.1.1"}, []string{}},
+                {[]string{"interfaces ethernet"}, []string{"eth0", "eth1", "eth2", "eth3", "eth4", "eth5"}},
+                {[]string{"interfaces ethernet eth9 address"}, []string{}},
+                {[]string{"system login user scott full-name"}, []string{"Scott Laird"}},
+                {[]string{"interfaces ethernet eth0 disable-flow-control"}, []string{""}},
+                {[]string{"interfaces", "ethernet", "eth1", "description"}, []string{"router1 to swa;swa;et18/1;SWA-ROUTER1;CORE;40000"}},
+        }
+
+        for _, test := range tests {
+                got, err := ast.Get(test.path...)
+                if err != nil {
+                        t.Errorf("Get(%q) failed: %v", test.path, err)
+                        continue
+                }
+                if !slices.Equal(got, test.want) {
+                        t.Errorf("Get(%q) = %q, want %q", test.path, got, test.want)
+                }
+        }
+
+        if ok, err := ast.Exists("service ssh"); !ok || err != nil {
+                t.Errorf("Exists(service ssh) = %v, %v; want true", ok, err)
+        }
+        if ok, err := ast.Exists("service dhcp-server"); ok || err != nil {
+                t.Errorf("Exists(service dhcp-server) = %v, %v; want false", ok, err)
+        }
+
+        _, err = ast.Get("interfaces ethernet eth0 adress")
+        var pe *ParseError
+        if !errors.As(err, &pe) {
+                t.Fatalf("Expected a ParseError, got %v", err)
+        }
+        if pe.Token != "adress" || !slices.Equal(pe.Path, []string{"interfaces", "ethernet", "eth0"}) || !slices.Contains(pe.Suggestions, "address") {
+                t.Errorf("Unexpected ParseError: %+v", pe)
+        }
+}
+
+func TestPathSetDelete(t *testing.T) {
+        configModel := getConfigModel(t)
+        ast, err := ParseSetFormat("set system host-name 'router'\n", configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        if err := ast.Set("interfaces ethernet eth0 address 10.0.0.1/24"); err != nil {
+                t.Fatalf("Set failed: %v", err)
+        }
+        if err := ast.Set("interfaces", "ethernet", "eth0", "description", "it's an uplink"); err != nil {
+                t.Fatalf("Set failed: %v", err)
+        }
+        if err := ast.Set("system host-name 'router 2'"); err != nil {
+                t.Fatalf("Set failed: %v", err)
+        }
+        if err := ast.Set("interfaces ethernet eth0"); err != nil {
+                t.Fatalf("Set failed: %v", err)
+        }
+        if err := ast.Set("interfaces ethernet"); err == nil {
+                t.Errorf("Expected an error setting a TagNode without a value")
+        }
+
+        if v, _ := ast.Get("interfaces ethernet eth0 description"); !slices.Equal(v, []string{"it's an uplink"}) {
+                t.Errorf("Description is %q", v)
+        }
+        if v, _ := ast.Get("system host-name"); !slices.Equal(v, []string{"router 2"}) {
+                t.Errorf("Host name is %q", v)
+        }
+
+        if err := ast.Delete("interfaces ethernet eth0 address"); err != nil {
+                t.Fatalf("Delete failed: %v", err)
+        }
+        if ok, _ := ast.Exists("interfaces ethernet eth0 address"); ok {
+                t.Errorf("Address still exists after Delete")
+        }
+        if err := ast.Delete("interfaces ethernet eth0 address"); err == nil {
+                t.Errorf("Expected an error deleting a missing node")
+        }
+}
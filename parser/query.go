@@ -0,0 +1,351 @@
+package parser
+
+import (
+        "fmt"
+        "strings"
+)
+
+// Query is a compiled query over config ASTs.  Queries are written
+// as a list of steps separated by `/`, much like XPath:
+//
+//      interfaces/ethernet/*[!description]
+//      firewall/**/rule/*[action=accept][destination/port=22]
+//      protocols/static/route/"16.0.0.0/8"/next-hop
+//
+// Each step is a config word.  TagNodes are followed by a step that
+// matches their value, like `ethernet/eth0`; if the query ends at the
+// TagNode's name, then every entry matches.  LeafNodes with values
+// may also be followed by a value step.  Words that contain `/`, `[`,
+// or `]` can be quoted with single or double quotes.
+//
+// Special steps:
+//
+//   - `*` matches any word or value.
+//   - `**` matches any number of levels of the config, including
+//     none.
+//
+// Steps can be followed by any number of predicates, which must all
+// be true for the step to match.  The paths inside predicates are
+// queries themselves, relative to the node that the step matched:
+//
+//   - `[path]` is true if anything matches `path`.
+//   - `[!path]` is true if nothing matches `path`.
+//   - `[path=value]` is true if anything matching `path` has the
+//     value `value`.
+//   - `[path!=value]` is true if nothing matching `path` has the
+//     value `value`, including when nothing matches at all.
+type Query struct {
+        steps []*queryStep
+}
+
+// queryStep is one `/`-separated step of a Query.
+type queryStep struct {
+        word       string // A config word or value, or `*` or `**`
+        predicates []*queryPredicate
+}
+
+// queryPredicate is a `[...]` condition on a queryStep.
+type queryPredicate struct {
+        negate bool   // `[!path]`
+        path   *Query // The path to test, relative to the step
+        op     string // "", "=", or "!="
+        value  string // The value to compare against, for `=` and `!=`
+}
+
+// QueryResult is a single node matched by a Query.
+type QueryResult struct {
+        Path []*PathElement // The full path to the node
+        Node *Node
+}
+
+// String returns the path to the result as a list of words, like
+// `interfaces ethernet eth0`.
+func (qr *QueryResult) String() string {
+        return strings.Join(pathWords(qr.Path), " ")
+}
+
+// CompileQuery parses a query string into a Query.  Syntax errors are
+// returned as a *ParseError.
+func CompileQuery(query string) (*Query, error) {
+        return compileQuery(query, 0)
+}
+
+// Query returns every node in the AST that matches `query`, in the
+// order that they appear in the config.
+func (vca *VyOSConfigAST) Query(query string) ([]*QueryResult, error) {
+        q, err := CompileQuery(query)
+        if err != nil {
+                return nil, err
+        }
+        return q.Match(vca), nil
+}
+
+// Match returns every node in the AST that matches the query, in the
+// order that they appear in the config.
+func (q *Query) Match(ast *VyOSConfigAST) []*QueryResult {
+        return q.match(ast.Child, []*PathElement{})
+}
+
+// match runs the query starting at `node`, which is at `path`.
+func (q *Query) match(node *Node, path []*PathElement) []*QueryResult {
+        results := []*QueryResult{}
+        seen := map[*Node]bool{}
+        q.matchNode(node, path, 0, &results, seen)
+        return results
+}
+
+// matchNode matches steps[i:] against the children of `n`, adding
+// matches to `results`.  `seen` prevents duplicates when `**` can
+// reach the same node more than one way.
+func (q *Query) matchNode(n *Node, path []*PathElement, i int, results *[]*QueryResult, seen map[*Node]bool) {
+        if i == len(q.steps) {
+                if n.ContextNode != nil && !seen[n] {
+                        seen[n] = true
+                        *results = append(*results, &QueryResult{Path: path, Node: n})
+                }
+                return
+        }
+
+        step := q.steps[i]
+        if step.word == "**" {
+                q.matchNode(n, path, i+1, results, seen)
+                for _, child := range n.Children {
+                        q.matchNode(child, pathTo(path, child), i, results, seen)
+                }
+                return
+        }
+
+        for _, child := range n.Children {
+                if step.word != "*" && step.word != child.ContextNode.Name {
+                        continue
+                }
+                if !step.test(child) {
+                        continue
+                }
+
+                // TagNodes and LeafNodes may have a step for their
+                // value.
+                next := i + 1
+                if child.Value != nil && next < len(q.steps) && q.steps[next].word != "**" {
+                        valueStep := q.steps[next]
+                        if valueStep.word != "*" && valueStep.word != *child.Value {
+                                continue
+                        }
+                        if !valueStep.test(child) {
+                                continue
+                        }
+                        next++
+                }
+
+                q.matchNode(child, pathTo(path, child), next, results, seen)
+        }
+}
+
+// test returns true if all of the step's predicates are true for
+// `n`.
+func (step *queryStep) test(n *Node) bool {
+        for _, p := range step.predicates {
+                if !p.test(n) {
+                        return false
+                }
+        }
+        return true
+}
+
+// test returns true if the predicate is true for `n`.
+func (p *queryPredicate) test(n *Node) bool {
+        matches := p.path.match(n, nil)
+
+        result := len(matches) > 0
+        if p.op != "" {
+                result = false
+                for _, m := range matches {
+                        value := ""
+                        if m.Node.Value != nil {
+                                value = *m.Node.Value
+                        }
+                        if value == p.value {
+                                result = true
+                                break
+                        }
+                }
+                if p.op == "!=" {
+                        result = !result
+                }
+        }
+
+        if p.negate {
+                return !result
+        }
+        return result
+}
+
+// compileQuery parses `query`, which starts at `offset` in the
+// original query string, for error reporting.
+func compileQuery(query string, offset int) (*Query, error) {
+        q := &Query{}
+        if strings.TrimSpace(query) == "" {
+                return nil, newQueryError(offset, query, "Empty query")
+        }
+
+        parts, starts, err := splitQuery(query, '/', offset)
+        if err != nil {
+                return nil, err
+        }
+        for i, part := range parts {
+                step, err := compileStep(part, starts[i])
+                if err != nil {
+                        return nil, err
+                }
+                q.steps = append(q.steps, step)
+        }
+        return q, nil
+}
+
+// compileStep parses a single step and its predicates.
+func compileStep(s string, offset int) (*queryStep, error) {
+        // The word runs until the first unquoted `[`.
+        end, err := scanQuery(s, offset, func(i int, r rune, depth int) bool {
+                return r == '['
+        })
+        if err != nil {
+                return nil, err
+        }
+        word := strings.TrimSpace(s[:end])
+        if word == "" {
+                return nil, newQueryError(offset, s, "Missing word in query")
+        }
+        step := &queryStep{word: unquote(word)}
+
+        rest := s[end:]
+        restOffset := offset + end
+        for rest != "" {
+                if rest[0] != '[' {
+                        return nil, newQueryError(restOffset, rest, "Unexpected text after predicate")
+                }
+                close, err := scanQuery(rest, restOffset, func(i int, r rune, depth int) bool {
+                        return r == ']' && depth == 1
+                })
+                if err != nil {
+                        return nil, err
+                }
+                if close == len(rest) {
+                        return nil, newQueryError(restOffset, rest, "Missing ']' in query")
+                }
+
+                p, err := compilePredicate(rest[1:close], restOffset+1)
+                if err != nil {
+                        return nil, err
+                }
+                step.predicates = append(step.predicates, p)
+                rest = rest[close+1:]
+                restOffset += close + 1
+        }
+        return step, nil
+}
+
+// compilePredicate parses the inside of a `[...]` predicate.
+func compilePredicate(s string, offset int) (*queryPredicate, error) {
+        p := &queryPredicate{}
+        if strings.HasPrefix(s, "!") && !strings.HasPrefix(s, "!=") {
+                p.negate = true
+                s = s[1:]
+                offset++
+        }
+
+        // Find an unquoted `=` or `!=` that isn't inside of a nested
+        // predicate.
+        eq, err := scanQuery(s, offset, func(i int, r rune, depth int) bool {
+                return r == '=' && depth == 0
+        })
+        if err != nil {
+                return nil, err
+        }
+
+        pathString := s
+        if eq < len(s) {
+                p.op = "="
+                pathString = s[:eq]
+                if strings.HasSuffix(pathString, "!") {
+                        p.op = "!="
+                        pathString = pathString[:len(pathString)-1]
+                }
+                p.value = unquote(strings.TrimSpace(s[eq+1:]))
+        }
+
+        p.path, err = compileQuery(pathString, offset)
+        if err != nil {
+                return nil, err
+        }
+        return p, nil
+}
+
+// splitQuery splits `s` on unquoted `sep` characters that aren't
+// inside of `[...]`.  It returns the parts and the offset of each.
+func splitQuery(s string, sep rune, offset int) ([]string, []int, error) {
+        parts := []string{}
+        starts := []int{}
+        start := 0
+        for {
+                end, err := scanQuery(s[start:], offset+start, func(i int, r rune, depth int) bool {
+                        return r == sep && depth == 0
+                })
+                if err != nil {
+                        return nil, nil, err
+                }
+                parts = append(parts, s[start:start+end])
+                starts = append(starts, offset+start)
+                if start+end == len(s) {
+                        return parts, starts, nil
+                }
+                start += end + 1
+        }
+}
+
+// scanQuery walks through `s`, skipping over quoted strings, and
+// returns the index of the first rune where `stop` returns true, or
+// len(s) if there isn't one.  `depth` is the number of unclosed `[`s,
+// counting the current rune if it's a `[`.
+func scanQuery(s string, offset int, stop func(i int, r rune, depth int) bool) (int, error) {
+        depth := 0
+        var quote rune
+        quoteStart := 0
+
+        for i, r := range s {
+                if quote != 0 {
+                        if r == quote {
+                                quote = 0
+                        }
+                        continue
+                }
+                switch r {
+                case '\'', '"':
+                        quote = r
+                        quoteStart = i
+                        continue
+                case '[':
+                        depth++
+                }
+                if stop(i, r, depth) {
+                        return i, nil
+                }
+                if r == ']' {
+                        depth--
+                        if depth < 0 {
+                                return 0, newQueryError(offset+i, "]", "Unexpected ']' in query")
+                        }
+                }
+        }
+        if quote != 0 {
+                return 0, newQueryError(offset+quoteStart, s[quoteStart:], "Unterminated quote in query")
+        }
+        return len(s), nil
+}
+
+// newQueryError returns a ParseError for a query syntax error at
+// 0-based `offset` in the query string.
+func newQueryError(offset int, token, message string) *ParseError {
+        pe := newParseError(0, offset+1, nil, fmt.Sprintf("%s at column %d", message, offset+1))
+        pe.Token = token
+        return pe
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+        "errors"
+        "os"
+        "slices"
+        "testing"
+)
+
+func TestQuery(t *testing.T) {
+        configModel := getConfigModel(t)
+        b, err := os.ReadFile("testdata/config.set.1")
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        ast, err := ParseSetFormat(string(b), configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        tests := []struct {
+                query string
+                want  []string
+        }{
+                {
+                        query: "interfaces/ethernet/*[!description]",
+                        want:  []string{"interfaces ethernet eth2", "interfaces ethernet eth3", "interfaces ethernet eth4", "interfaces ethernet eth5"},
+                },
+                {
+                        query: "interfaces/ethernet/eth0/address",
+                        want:  []string{"interfaces ethernet eth0 address 10.250.1.254/24"},
+                },
+                {
+                        query: "interfaces/*/*[address=dhcp]",
+                        want:  []string{"interfaces ethernet eth5"},
+                },
+                {
+                        query: "firewall/**/rule/*[action=accept][destination/address='16.0.0.0/8']",
+                        want:  []string{"firewall ipv4 forward filter rule 900"},
+                },
+                {
+                        query: "firewall/**/rule/*[action!=accept]",
+                        want:  []string{"firewall ipv4 forward filter rule 10", "firewall ipv4 forward filter rule 999"},
+                },
+                {
+                        query: "protocols/static/route/\"48.0.0.0/8\"/next-hop",
+                        want:  []string{"protocols static route 48.0.0.0/8 next-hop 10.250.1.1"},
+                },
+                {
+                        query: "**/mac",
+                        want:  []string{"protocols static arp interface eth0 address 10.250.1.1 mac e4:1d:2d:af:60:6c", "protocols static arp interface eth1 address 10.250.0.1 mac 98:03:9b:77:95:e6"},
+                },
+                {
+                        query: "system/name-server/8.8.8.8",
+                        want:  []string{"system name-server 8.8.8.8"},
+                },
+                {
+                        query: "system/login/user/*[authentication/public-keys]",
+                        want:  []string{"system login user scott"},
+                },
+                {
+                        query: "service/ntp/server",
+                        want:  []string{"service ntp server 0.pool.ntp.org", "service ntp server 1.pool.ntp.org", "service ntp server 2.pool.ntp.org", "service ntp server 10.1.0.238", "service ntp server 10.1.0.239"},
+                },
+                {
+                        query: "interfaces/ethernet/*[offload[hw-tc-offload]]",
+                        want:  []string{"interfaces ethernet eth0", "interfaces ethernet eth1"},
+                },
+                {
+                        query: "service/dhcp-server",
+                        want:  []string{},
+                },
+        }
+
+        for _, test := range tests {
+                results, err := ast.Query(test.query)
+                if err != nil {
+                        t.Errorf("Query(%q) failed: %v", test.query, err)
+                        continue
+                }
+                got := []string{}
+                for _, r := range results {
+                        got = append(got, r.String())
+                }
+                if !slices.Equal(got, test.want) {
+                        t.Errorf("Query(%q) = %q, want %q", test.query, got, test.want)
+                }
+        }
+}
+
+func TestQueryErrors(t *testing.T) {
+        tests := []struct {
+                query  string
+                column int
+        }{
+                {"", 1},
+                {"interfaces//ethernet", 12},
+                {"interfaces/ethernet/*[description", 22},
+                {"interfaces/ethernet/*]", 22},
+                {"interfaces/'ethernet", 12},
+                {"interfaces/ethernet/*[address]x", 31},
+        }
+
+        for _, test := range tests {
+                _, err := CompileQuery(test.query)
+                var pe *ParseError
+                if !errors.As(err, &pe) {
+                        t.Errorf("CompileQuery(%q) returned %v, want a ParseError", test.query, err)
+                        continue
+                }
+                if pe.Column != test.column {
+                        t.Errorf("CompileQuery(%q) error at column %d, want %d: %v", test.query, pe.Column, test.column, err)
+                }
+        }
+}
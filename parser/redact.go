@@ -0,0 +1,163 @@
+package parser
+
+import (
+        "slices"
+)
+
+// RedactedValue replaces secret values in redacted configs.
+const RedactedValue = "xxxxxx"
+
+// DefaultRedactQueries lists the values that are always redacted,
+// even if the config model doesn't mark them as secret: the
+// passwords, keys, and other secrets that VyOS's `strip-private`
+// removes.  Each one is a full path, so that look-alikes such as
+// firewall GRE keys and public keys aren't redacted.
+var DefaultRedactQueries = []string{
+        "container/registry/*/authentication/password",
+        "high-availability/vrrp/group/*/authentication/password",
+        "interfaces/bonding/*/eapol/passphrase",
+        "interfaces/ethernet/*/eapol/passphrase",
+        "interfaces/macsec/*/security/static/key",
+        "interfaces/macsec/*/security/static/peer/*/key",
+        "interfaces/openvpn/*/authentication/password",
+        "interfaces/openvpn/*/shared-secret-key",
+        "interfaces/openvpn/*/tls/auth-key",
+        "interfaces/pppoe/*/authentication/password",
+        "interfaces/sstpc/*/authentication/password",
+        "interfaces/wireguard/*/peer/*/preshared-key",
+        "interfaces/wireguard/*/private-key",
+        "interfaces/wireless/*/security/wep/key",
+        "interfaces/wireless/*/security/wpa/passphrase",
+        "interfaces/wireless/*/security/wpa/radius/server/*/key",
+        "interfaces/wwan/*/authentication/password",
+        "pki/ca/*/private/key",
+        "pki/certificate/*/private/key",
+        "pki/key-pair/*/private/key",
+        "pki/openssh/*/private/key",
+        "pki/openvpn/shared-secret/*/key",
+        "protocols/bgp/neighbor/*/password",
+        "protocols/bgp/peer-group/*/password",
+        "protocols/isis/area-password/plaintext-password",
+        "protocols/isis/domain-password/plaintext-password",
+        "protocols/isis/interface/*/password/plaintext-password",
+        "protocols/mpls/ldp/neighbor/*/password",
+        "protocols/openfabric/domain/*/domain-password/plaintext-password",
+        "protocols/openfabric/domain/*/interface/*/password/plaintext-password",
+        "protocols/ospf/area/*/virtual-link/*/authentication/md5/key-id/*/md5-key",
+        "protocols/ospf/area/*/virtual-link/*/authentication/plaintext-password",
+        "protocols/ospf/interface/*/authentication/md5/key-id/*/md5-key",
+        "protocols/ospf/interface/*/authentication/plaintext-password",
+        "protocols/rip/interface/*/authentication/md5/*/password",
+        "protocols/rip/interface/*/authentication/plaintext-password",
+        "service/config-sync/secondary/key",
+        "service/dns/dynamic/name/*/password",
+        "service/https/api/keys/id/*/key",
+        "service/ipoe-server/authentication/radius/dynamic-author/key",
+        "service/ipoe-server/authentication/radius/server/*/key",
+        "service/monitoring/telegraf/influxdb/authentication/token",
+        "service/monitoring/telegraf/loki/authentication/password",
+        "service/monitoring/telegraf/prometheus-client/authentication/password",
+        "service/monitoring/telegraf/splunk/authentication/token",
+        "service/monitoring/zabbix-agent/authentication/psk/secret",
+        "service/pppoe-server/authentication/local-users/username/*/password",
+        "service/pppoe-server/authentication/radius/dynamic-author/key",
+        "service/pppoe-server/authentication/radius/server/*/key",
+        "service/snmp/community",
+        "service/snmp/trap-target/*/community",
+        "service/snmp/v3/trap-target/*/auth/encrypted-password",
+        "service/snmp/v3/trap-target/*/auth/plaintext-password",
+        "service/snmp/v3/trap-target/*/privacy/encrypted-password",
+        "service/snmp/v3/trap-target/*/privacy/plaintext-password",
+        "service/snmp/v3/user/*/auth/encrypted-password",
+        "service/snmp/v3/user/*/auth/plaintext-password",
+        "service/snmp/v3/user/*/privacy/encrypted-password",
+        "service/snmp/v3/user/*/privacy/plaintext-password",
+        "service/stunnel/client/*/options/password",
+        "service/stunnel/client/*/psk/*/secret",
+        "service/stunnel/server/*/psk/*/secret",
+        "service/webproxy/authentication/ldap/password",
+        "system/login/radius/server/*/key",
+        "system/login/tacacs/server/*/key",
+        "system/login/user/*/authentication/encrypted-password",
+        "system/login/user/*/authentication/otp/key",
+        "system/login/user/*/authentication/plaintext-password",
+        "system/proxy/password",
+        "vpn/ipsec/authentication/psk/*/secret",
+        "vpn/ipsec/profile/*/authentication/pre-shared-secret",
+        "vpn/ipsec/remote-access/connection/*/authentication/local-users/username/*/password",
+        "vpn/ipsec/remote-access/connection/*/authentication/pre-shared-secret",
+        "vpn/ipsec/remote-access/connection/*/authentication/x509/passphrase",
+        "vpn/ipsec/remote-access/radius/server/*/key",
+        "vpn/ipsec/site-to-site/peer/*/authentication/rsa/passphrase",
+        "vpn/ipsec/site-to-site/peer/*/authentication/x509/passphrase",
+        "vpn/l2tp/remote-access/authentication/local-users/username/*/password",
+        "vpn/l2tp/remote-access/authentication/radius/dynamic-author/key",
+        "vpn/l2tp/remote-access/authentication/radius/server/*/key",
+        "vpn/l2tp/remote-access/ipsec-settings/authentication/pre-shared-secret",
+        "vpn/l2tp/remote-access/ipsec-settings/authentication/x509/passphrase",
+        "vpn/openconnect/accounting/radius/server/*/key",
+        "vpn/openconnect/authentication/local-users/username/*/otp/key",
+        "vpn/openconnect/authentication/local-users/username/*/password",
+        "vpn/openconnect/authentication/radius/server/*/key",
+        "vpn/openconnect/ssl/passphrase",
+        "vpn/pptp/remote-access/authentication/local-users/username/*/password",
+        "vpn/pptp/remote-access/authentication/radius/dynamic-author/key",
+        "vpn/pptp/remote-access/authentication/radius/server/*/key",
+        "vpn/sstp/authentication/local-users/username/*/password",
+        "vpn/sstp/authentication/radius/dynamic-author/key",
+        "vpn/sstp/authentication/radius/server/*/key",
+        "vrf/name/*/protocols/bgp/neighbor/*/password",
+        "vrf/name/*/protocols/bgp/peer-group/*/password",
+        "vrf/name/*/protocols/isis/area-password/plaintext-password",
+        "vrf/name/*/protocols/isis/domain-password/plaintext-password",
+        "vrf/name/*/protocols/isis/interface/*/password/plaintext-password",
+        "vrf/name/*/protocols/ospf/area/*/virtual-link/*/authentication/md5/key-id/*/md5-key",
+        "vrf/name/*/protocols/ospf/area/*/virtual-link/*/authentication/plaintext-password",
+        "vrf/name/*/protocols/ospf/interface/*/authentication/md5/key-id/*/md5-key",
+        "vrf/name/*/protocols/ospf/interface/*/authentication/plaintext-password",
+}
+
+// Redact returns a copy of the AST with secret values replaced by
+// RedactedValue.  Values are secret if the config model marks them
+// as secret, or if they match one of DefaultRedactQueries or
+// `queries`.  A query that matches a TagNode redacts each entry's
+// value, so SNMP communities become `community xxxxxx`.  It returns a
+// *ParseError if one of `queries` is invalid.
+func (vca *VyOSConfigAST) Redact(queries ...string) (*VyOSConfigAST, error) {
+        c := vca.Copy()
+        redactSecrets(c.Child)
+
+        for _, query := range slices.Concat(DefaultRedactQueries, queries) {
+                q, err := CompileQuery(query)
+                if err != nil {
+                        return nil, err
+                }
+                for _, result := range q.Match(c) {
+                        if result.Node.Value != nil {
+                                value := RedactedValue
+                                result.Node.Value = &value
+                        }
+                }
+        }
+        return c, nil
+}
+
+// redactSecrets redacts the values of `n` and everything underneath
+// it that the config model marks as secret.
+func redactSecrets(n *Node) {
+        if n.ContextNode != nil && n.ContextNode.Secret && n.Value != nil {
+                value := RedactedValue
+                n.Value = &value
+        }
+        for _, child := range n.Children {
+                redactSecrets(child)
+        }
+}
+
+// prepare returns the AST to write, after applying the options.
+func (wo *WriteOptions) prepare(ast *VyOSConfigAST) (*VyOSConfigAST, error) {
+        if wo == nil || !wo.Redact {
+                return ast, nil
+        }
+        return ast.Redact(wo.RedactQueries...)
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+        "encoding/xml"
+        "os"
+        "strings"
+        "testing"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+func TestRedact(t *testing.T) {
+        configModel := getConfigModel(t)
+        b, err := os.ReadFile("testdata/config.set.1")
+        if err != nil {
+                t.Fatalf("Failed to read config: %v", err)
+        }
+        ast, err := ParseSetFormat(string(b), configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        options := &WriteOptions{
+                Redact:        true,
+                RedactQueries: []string{"system/host-name"},
+        }
+        set, err := WriteSetFormatWithOptions(ast, options)
+        if err != nil {
+                t.Fatalf("Failed to write set format: %v", err)
+        }
+        for _, want := range []string{
+                "set service snmp community xxxxxx authorization 'ro'",
+                "set system login user scott authentication encrypted-password 'xxxxxx'",
+                "set system login user vyos authentication encrypted-password 'xxxxxx'",
+                "set system host-name 'xxxxxx'",
+                "set system login user scott authentication public-keys scott@fs2 key 'AAAAxxxxxxxxx'",
+        } {
+                if !strings.Contains(set, want+"\n") {
+                        t.Errorf("Expected redacted output to contain %q", want)
+                }
+        }
+        if strings.Contains(set, "$6$") || strings.Contains(set, "community public") {
+                t.Errorf("Redacted output still contains secrets:\n%s", set)
+        }
+
+        show, err := WriteShowFormatWithOptions(ast, options)
+        if err != nil {
+                t.Fatalf("Failed to write show format: %v", err)
+        }
+        if !strings.Contains(show, "encrypted-password xxxxxx\n") || strings.Contains(show, "$6$") {
+                t.Errorf("Show output wasn't redacted:\n%s", show)
+        }
+
+        boot, err := WriteConfigBootFormatWithOptions(ast, options)
+        if err != nil {
+                t.Fatalf("Failed to write config.boot format: %v", err)
+        }
+        if !strings.Contains(boot, `encrypted-password "xxxxxx"`) || strings.Contains(boot, "$6$") {
+                t.Errorf("config.boot output wasn't redacted:\n%s", boot)
+        }
+
+        // The original AST is left alone.
+        unredacted, err := WriteSetFormat(ast)
+        if err != nil {
+                t.Fatalf("Failed to write set format: %v", err)
+        }
+        if unredacted != string(b) {
+                t.Errorf("Redacting changed the original AST")
+        }
+
+        if _, err := WriteSetFormatWithOptions(ast, &WriteOptions{Redact: true, RedactQueries: []string{"system/["}}); err == nil {
+                t.Errorf("Expected an error for an invalid query")
+        }
+}
+
+func TestDefaultRedactQueries(t *testing.T) {
+        configModel := getConfigModel(t)
+
+        // Every query is a real path in the config model, and
+        // redacts the value there.
+        for _, query := range DefaultRedactQueries {
+                line := "set " + strings.ReplaceAll(strings.ReplaceAll(query, "*", "x"), "/", " ") + " 'hunter2'\n"
+                ast, err := ParseSetFormat(line, configModel)
+                if err != nil {
+                        t.Errorf("Failed to parse %q: %v", line, err)
+                        continue
+                }
+                set, err := WriteSetFormatWithOptions(ast, &WriteOptions{Redact: true})
+                if err != nil {
+                        t.Fatalf("Failed to write set format: %v", err)
+                }
+                if strings.Contains(set, "hunter2") {
+                        t.Errorf("Query %q didn't redact %q", query, set)
+                }
+        }
+
+        // Values that just happen to be called `key` aren't secret.
+        config := `set firewall ipv4 name WAN rule 10 gre key '1234'
+set interfaces tunnel tun0 parameters ip key '5678'
+set pki key-pair kp1 public key 'MIIBIjANBg'
+`
+        ast, err := ParseSetFormat(config, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        set, err := WriteSetFormatWithOptions(ast, &WriteOptions{Redact: true})
+        if err != nil {
+                t.Fatalf("Failed to write set format: %v", err)
+        }
+        if set != config {
+                t.Errorf("Got:\n%s\nWant:\n%s", set, config)
+        }
+}
+
+func TestRedactSecretModel(t *testing.T) {
+        id := &configmodel.InterfaceDefinition{}
+        err := xml.Unmarshal([]byte(`<interfaceDefinition>
+  <node name="interfaces">
+    <children>
+      <tagNode name="wireguard">
+        <children>
+          <leafNode name="description"/>
+          <leafNode name="psk">
+            <properties>
+              <secret/>
+            </properties>
+          </leafNode>
+        </children>
+      </tagNode>
+    </children>
+  </node>
+</interfaceDefinition>`), id)
+        if err != nil {
+                t.Fatalf("Failed to parse XML: %v", err)
+        }
+
+        ast, err := ParseSetFormat(`set interfaces wireguard wg0 description 'Backhaul'
+set interfaces wireguard wg0 psk 'hunter2'
+`, id.VyOSConfig())
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        set, err := WriteSetFormatWithOptions(ast, &WriteOptions{Redact: true})
+        if err != nil {
+                t.Fatalf("Failed to write set format: %v", err)
+        }
+        want := `set interfaces wireguard wg0 description 'Backhaul'
+set interfaces wireguard wg0 psk 'xxxxxx'
+`
+        if set != want {
+                t.Errorf("Got:\n%s\nWant:\n%s", set, want)
+        }
+}
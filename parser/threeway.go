@@ -0,0 +1,278 @@
+package parser
+
+import (
+        "fmt"
+        "strings"
+)
+
+// Conflict describes a change that ThreeWayMerge couldn't merge
+// automatically.
+type Conflict struct {
+        Path    []*PathElement // The path to the conflicting node
+        Base    *Node          // The node in the base AST, or nil
+        Ours    *Node          // The node in our AST, or nil if we deleted it
+        Theirs  *Node          // The node in their AST, or nil if they deleted it
+        Message string         // A description of the conflict
+
+        parent      *Node // The parent of the conflict in the merged AST
+        merged      *Node // The conflicting node in the merged AST, or nil
+        commentOnly bool  // Only the comments conflict
+}
+
+func (c *Conflict) String() string {
+        return fmt.Sprintf("%s: %s", strings.Join(pathWords(c.Path), " "), c.Message)
+}
+
+// ThreeWayMerge merges two configs (`ours` and `theirs`) that were
+// both derived from `base`.  Changes made on only one side are
+// applied automatically, including changes to different LeafNodes
+// or TagNode entries under the same parent.  When both sides change
+// the same single-value LeafNode or comment differently, or one side
+// deletes a subtree that the other side modified, the change is
+// reported as a Conflict and the merged AST keeps our version.
+func ThreeWayMerge(base, ours, theirs *VyOSConfigAST) (*VyOSConfigAST, []*Conflict) {
+        merged := ours.Copy()
+        merged.Child = &Node{Type: "root"}
+        conflicts := mergeNodes(merged.Child, base.Child, ours.Child, theirs.Child, []*PathElement{})
+        return merged, conflicts
+}
+
+// mergeNodes merges the children of three matching nodes into
+// `merged`.  `base` may be nil, if both sides added the node.
+func mergeNodes(merged, base, ours, theirs *Node, path []*PathElement) []*Conflict {
+        conflicts := []*Conflict{}
+
+        baseChildren := map[string]*Node{}
+        if base != nil {
+                for _, child := range base.Children {
+                        baseChildren[diffKey(child)] = child
+                }
+        }
+
+        // Keep our order, with their additions placed where they
+        // appeared in their config.
+        for _, pair := range pairChildren(theirs, ours) {
+                o, t := pair.to, pair.from
+                var key string
+                if o != nil {
+                        key = diffKey(o)
+                } else {
+                        key = diffKey(t)
+                }
+                b := baseChildren[key]
+
+                switch {
+                case o == nil && b == nil:
+                        // They added it.
+                        merged.Children = append(merged.Children, t.Copy())
+                case t == nil && b == nil:
+                        // We added it.
+                        merged.Children = append(merged.Children, o.Copy())
+                case o == nil:
+                        // We deleted it; that's fine unless they changed it.
+                        if !equalTrees(b, t) {
+                                conflicts = append(conflicts, &Conflict{
+                                        Path: conflictPath(path, t), Base: b, Theirs: t, parent: merged,
+                                        Message: "Deleted in ours but modified in theirs",
+                                })
+                        }
+                case t == nil:
+                        if equalTrees(b, o) {
+                                continue
+                        }
+                        m := o.Copy()
+                        merged.Children = append(merged.Children, m)
+                        conflicts = append(conflicts, &Conflict{
+                                Path: conflictPath(path, o), Base: b, Ours: o, parent: merged, merged: m,
+                                Message: "Modified in ours but deleted in theirs",
+                        })
+                case o.Type == "leafnode":
+                        m := o.Copy()
+                        merged.Children = append(merged.Children, m)
+                        value, ok := mergeValue(b, o, t)
+                        if !ok {
+                                conflicts = append(conflicts, &Conflict{
+                                        Path: conflictPath(path, o), Base: b, Ours: o, Theirs: t, parent: merged, merged: m,
+                                        Message: "Changed to different values",
+                                })
+                                continue
+                        }
+                        m.Value = value
+                        conflicts = append(conflicts, mergeComment(m, b, o, t, path, merged)...)
+                default:
+                        m := &Node{ContextNode: o.ContextNode, Type: o.Type, Value: o.Value, Comment: o.Comment}
+                        merged.Children = append(merged.Children, m)
+                        nodeConflicts := mergeComment(m, b, o, t, path, merged)
+                        nodeConflicts = append(nodeConflicts, mergeNodes(m, b, o, t, pathTo(path, o))...)
+                        conflicts = append(conflicts, nodeConflicts...)
+
+                        // If each side deleted different children,
+                        // the merge can leave an empty node that
+                        // neither side had.
+                        if m.Type == "node" && len(m.Children) == 0 && len(o.Children) > 0 && len(t.Children) > 0 && len(nodeConflicts) == 0 {
+                                merged.Children = merged.Children[:len(merged.Children)-1]
+                        }
+                }
+        }
+        return conflicts
+}
+
+// conflictPath returns the path to `n`.  Single-value LeafNodes are
+// identified by name alone, because their value is what conflicts.
+func conflictPath(path []*PathElement, n *Node) []*PathElement {
+        p := pathTo(path, n)
+        if n.Type == "leafnode" && !n.ContextNode.Multi {
+                p[len(p)-1].Value = nil
+        }
+        return p
+}
+
+// mergeValue does a three-way merge of a LeafNode's value.  It
+// returns false if both sides changed the value differently.
+func mergeValue(base, ours, theirs *Node) (*string, bool) {
+        switch {
+        case equalValues(ours.Value, theirs.Value):
+                return ours.Value, true
+        case base != nil && equalValues(base.Value, ours.Value):
+                return theirs.Value, true
+        case base != nil && equalValues(base.Value, theirs.Value):
+                return ours.Value, true
+        }
+        return nil, false
+}
+
+// mergeComment does a three-way merge of the comments on a node,
+// storing the result in `m`.
+func mergeComment(m, base, ours, theirs *Node, path []*PathElement, parent *Node) []*Conflict {
+        baseComment := ""
+        if base != nil {
+                baseComment = base.Comment
+        }
+        switch {
+        case ours.Comment == theirs.Comment || theirs.Comment == baseComment:
+                m.Comment = ours.Comment
+        case ours.Comment == baseComment:
+                m.Comment = theirs.Comment
+        default:
+                m.Comment = ours.Comment
+                return []*Conflict{{
+                        Path: conflictPath(path, ours), Base: base, Ours: ours, Theirs: theirs,
+                        Message: "Comment changed to different text", parent: parent, merged: m, commentOnly: true,
+                }}
+        }
+        return nil
+}
+
+// equalTrees returns true if two nodes and everything underneath them
+// are the same.
+func equalTrees(a, b *Node) bool {
+        return equalValues(a.Value, b.Value) && a.Comment == b.Comment && len(diffNodes(a, b, nil)) == 0
+}
+
+// WriteConflictShowFormat writes a merged AST from ThreeWayMerge in
+// `show` format, with each conflict surrounded by git-style conflict
+// markers showing both versions:
+//
+//      <<<<<<< ours
+//               description "our text"
+//      =======
+//               description "their text"
+//      >>>>>>> theirs
+//
+// Conflicts over comments only show the comments in the markers.
+func WriteConflictShowFormat(ast *VyOSConfigAST, conflicts []*Conflict) (string, error) {
+        byParent := map[*Node][]*Conflict{}
+        for _, c := range conflicts {
+                byParent[c.parent] = append(byParent[c.parent], c)
+        }
+        results, err := writeConflictPartial(ast.Child, 1, byParent)
+        if err != nil {
+                return "", err
+        }
+        return strings.Join(results, "\n") + "\n", nil
+}
+
+// writeConflictPartial is like writeShowPartial, but adds conflict
+// markers.
+func writeConflictPartial(node *Node, indent int, byParent map[*Node][]*Conflict) ([]string, error) {
+        results := []string{}
+        conflicts := byParent[node]
+
+        for _, child := range node.Children {
+                var conflict *Conflict
+                for _, c := range conflicts {
+                        if c.merged == child {
+                                conflict = c
+                        }
+                }
+
+                if conflict != nil && !conflict.commentOnly {
+                        lines, err := conflictMarkers(conflict, indent)
+                        if err != nil {
+                                return nil, err
+                        }
+                        results = append(results, lines...)
+                        continue
+                }
+
+                if conflict != nil {
+                        results = append(results, "<<<<<<< ours")
+                        if conflict.Ours.Comment != "" {
+                                results = append(results, writeComment(conflict.Ours.Comment, indent))
+                        }
+                        results = append(results, "=======")
+                        if conflict.Theirs.Comment != "" {
+                                results = append(results, writeComment(conflict.Theirs.Comment, indent))
+                        }
+                        results = append(results, ">>>>>>> theirs")
+                } else if child.Comment != "" {
+                        results = append(results, writeComment(child.Comment, indent))
+                }
+
+                if child.Type == "leafnode" {
+                        results = append(results, spaces(indent)+showLine(child))
+                        continue
+                }
+                results = append(results, spaces(indent)+showLine(child)+" {")
+                lines, err := writeConflictPartial(child, indent+4, byParent)
+                if err != nil {
+                        return nil, err
+                }
+                results = append(results, lines...)
+                results = append(results, spaces(indent)+"}")
+        }
+
+        // Conflicts where we deleted the node aren't in the merged
+        // AST, so they go at the end.
+        for _, c := range conflicts {
+                if c.merged == nil {
+                        lines, err := conflictMarkers(c, indent)
+                        if err != nil {
+                                return nil, err
+                        }
+                        results = append(results, lines...)
+                }
+        }
+        return results, nil
+}
+
+// conflictMarkers returns both sides of a conflict, between markers.
+func conflictMarkers(c *Conflict, indent int) ([]string, error) {
+        results := []string{"<<<<<<< ours"}
+        if c.Ours != nil {
+                lines, err := writeShowPartial(c.Ours, indent)
+                if err != nil {
+                        return nil, err
+                }
+                results = append(results, lines...)
+        }
+        results = append(results, "=======")
+        if c.Theirs != nil {
+                lines, err := writeShowPartial(c.Theirs, indent)
+                if err != nil {
+                        return nil, err
+                }
+                results = append(results, lines...)
+        }
+        return append(results, ">>>>>>> theirs"), nil
+}
@@ -0,0 +1,220 @@
+package parser
+
+import (
+        "testing"
+
+        "github.com/hexops/gotextdiff"
+        "github.com/hexops/gotextdiff/myers"
+)
+
+func TestThreeWayMerge(t *testing.T) {
+        configModel := getConfigModel(t)
+        parse := func(config string) *VyOSConfigAST {
+                ast, err := ParseSetFormat(config, configModel)
+                if err != nil {
+                        t.Fatalf("Failed to parse config: %v", err)
+                }
+                return ast
+        }
+
+        base := parse(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 description 'uplink'
+set interfaces ethernet eth1 description 'spare'
+set system host-name 'router'
+set system name-server '8.8.8.8'
+`)
+        ours := parse(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth0 description 'uplink to isp'
+set interfaces ethernet eth1 description 'spare'
+set system host-name 'router1'
+set system name-server '8.8.8.8'
+`)
+        theirs := parse(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 description 'uplink'
+set interfaces ethernet eth0 mtu '9000'
+set system host-name 'router2'
+set system name-server '8.8.8.8'
+set system name-server '1.1.1.1'
+set system time-zone 'UTC'
+`)
+
+        merged, conflicts := ThreeWayMerge(base, ours, theirs)
+        if len(conflicts) != 1 {
+                t.Fatalf("Got %d conflicts, want 1: %v", len(conflicts), conflicts)
+        }
+        if got, want := conflicts[0].String(), "system host-name: Changed to different values"; got != want {
+                t.Errorf("Conflict is %q, want %q", got, want)
+        }
+        if *conflicts[0].Base.Value != "router" || *conflicts[0].Ours.Value != "router1" || *conflicts[0].Theirs.Value != "router2" {
+                t.Errorf("Unexpected conflict values: %+v", conflicts[0])
+        }
+
+        got, err := WriteSetFormat(merged)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        want := `set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 address '10.0.0.2/24'
+set interfaces ethernet eth0 description 'uplink to isp'
+set interfaces ethernet eth0 mtu '9000'
+set system host-name 'router1'
+set system name-server '8.8.8.8'
+set system name-server '1.1.1.1'
+set system time-zone 'UTC'
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected merge result:\n%s", diff)
+        }
+
+        got, err = WriteConflictShowFormat(merged, conflicts)
+        if err != nil {
+                t.Fatalf("WriteConflictShowFormat failed: %v", err)
+        }
+        want = ` interfaces {
+     ethernet eth0 {
+         address 10.0.0.1/24
+         address 10.0.0.2/24
+         description "uplink to isp"
+         mtu 9000
+     }
+ }
+ system {
+<<<<<<< ours
+     host-name router1
+=======
+     host-name router2
+>>>>>>> theirs
+     name-server 8.8.8.8
+     name-server 1.1.1.1
+     time-zone UTC
+ }
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected conflict output:\n%s", diff)
+        }
+}
+
+func TestThreeWayMergeDeleteConflicts(t *testing.T) {
+        configModel := getConfigModel(t)
+        parse := func(config string) *VyOSConfigAST {
+                ast, err := ParseSetFormat(config, configModel)
+                if err != nil {
+                        t.Fatalf("Failed to parse config: %v", err)
+                }
+                return ast
+        }
+
+        base := parse(`set interfaces ethernet eth0 description 'a'
+set interfaces ethernet eth1 description 'b'
+set interfaces ethernet eth2 description 'c'
+`)
+        ours := parse(`set interfaces ethernet eth0 description 'a2'
+set interfaces ethernet eth2 description 'c'
+comment interfaces ethernet eth2 'ours'
+`)
+        theirs := parse(`set interfaces ethernet eth1 description 'b2'
+set interfaces ethernet eth2 description 'c'
+comment interfaces ethernet eth2 'theirs'
+`)
+
+        merged, conflicts := ThreeWayMerge(base, ours, theirs)
+        messages := []string{}
+        for _, c := range conflicts {
+                messages = append(messages, c.String())
+        }
+        want := []string{
+                "interfaces ethernet eth1: Deleted in ours but modified in theirs",
+                "interfaces ethernet eth0: Modified in ours but deleted in theirs",
+                "interfaces ethernet eth2: Comment changed to different text",
+        }
+        if len(messages) != len(want) {
+                t.Fatalf("Got conflicts %q, want %q", messages, want)
+        }
+        for i := range want {
+                if messages[i] != want[i] {
+                        t.Errorf("Conflict %d is %q, want %q", i, messages[i], want[i])
+                }
+        }
+
+        got, err := WriteConflictShowFormat(merged, conflicts)
+        if err != nil {
+                t.Fatalf("WriteConflictShowFormat failed: %v", err)
+        }
+        wantShow := ` interfaces {
+<<<<<<< ours
+     ethernet eth0 {
+         description a2
+     }
+=======
+>>>>>>> theirs
+<<<<<<< ours
+     /* ours */
+=======
+     /* theirs */
+>>>>>>> theirs
+     ethernet eth2 {
+         description c
+     }
+<<<<<<< ours
+=======
+     ethernet eth1 {
+         description b2
+     }
+>>>>>>> theirs
+ }
+`
+        if got != wantShow {
+                edits := myers.ComputeEdits("want", wantShow, got)
+                diff := gotextdiff.ToUnified("want", "got", wantShow, edits)
+                t.Errorf("Unexpected conflict output:\n%s", diff)
+        }
+}
+
+func TestThreeWayMergePrunesEmptyNodes(t *testing.T) {
+        configModel := getConfigModel(t)
+        parse := func(config string) *VyOSConfigAST {
+                ast, err := ParseSetFormat(config, configModel)
+                if err != nil {
+                        t.Fatalf("Failed to parse config: %v", err)
+                }
+                return ast
+        }
+
+        base := parse(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 offload gro
+set interfaces ethernet eth0 offload tso
+set service ssh
+`)
+        ours := parse(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 offload tso
+set service ssh
+`)
+        theirs := parse(`set interfaces ethernet eth0 address '10.0.0.1/24'
+set interfaces ethernet eth0 offload gro
+set service ssh
+`)
+
+        merged, conflicts := ThreeWayMerge(base, ours, theirs)
+        if len(conflicts) != 0 {
+                t.Fatalf("Got conflicts %v, want none", conflicts)
+        }
+        got, err := WriteSetFormat(merged)
+        if err != nil {
+                t.Fatalf("WriteSetFormat failed: %v", err)
+        }
+        // `offload` is gone, but `service ssh` was empty on both
+        // sides and stays.
+        want := `set interfaces ethernet eth0 address '10.0.0.1/24'
+set service ssh
+`
+        if got != want {
+                edits := myers.ComputeEdits("want", want, got)
+                diff := gotextdiff.ToUnified("want", "got", want, edits)
+                t.Errorf("Unexpected merge result:\n%s", diff)
+        }
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+        "fmt"
+        "regexp"
+        "sync"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// ValidatorFunc checks a value against one of VyOS's validators.  It
+// gets the value being checked and the validator's `argument` string
+// from the config model, and returns nil if the value is valid.
+type ValidatorFunc func(value, argument string) error
+
+var (
+        validatorsMu sync.RWMutex // Protects `validators`
+
+        regexCache sync.Map // Regex string -> *regexp.Regexp, or nil if it doesn't compile
+)
+
+// RegisterValidator adds (or replaces) a validator, so that
+// constraints in the config model that refer to `name` can be
+// checked.
+func RegisterValidator(name string, f ValidatorFunc) {
+        validatorsMu.Lock()
+        defer validatorsMu.Unlock()
+        validators[name] = f
+}
+
+// lookupValidator returns the validator named `name`, or nil.
+func lookupValidator(name string) ValidatorFunc {
+        validatorsMu.RLock()
+        defer validatorsMu.RUnlock()
+        return validators[name]
+}
+
+// Validate checks every LeafNode and TagNode value in the AST against
+// the constraints in the config model.  It returns nil if everything
+// is valid, or a ParseErrors listing every invalid value.  Each
+// ParseError's Path ends with the name of the node, Token holds the
+// invalid value, and Message includes VyOS's error message for the
+// constraint, if it has one.
+//
+// Constraints that refer to validators that haven't been registered,
+// or to regular expressions that Go can't compile, can't be checked,
+// so values are assumed to match them.
+func Validate(ast *VyOSConfigAST) error {
+        errs := ParseErrors{}
+        validateNode(ast.Child, []string{}, &errs)
+        if len(errs) == 0 {
+                return nil
+        }
+        return errs
+}
+
+// validateNode checks `n` and everything underneath it.
+func validateNode(n *Node, path []string, errs *ParseErrors) {
+        if n.ContextNode != nil {
+                path = append(path, n.ContextNode.Name)
+                if n.Value != nil && !checkConstraint(n.ContextNode.Constraint, *n.Value) {
+                        *errs = append(*errs, newConstraintError(path, n.ContextNode, *n.Value))
+                }
+                if n.Value != nil && n.Type == "tagnode" {
+                        path = append(path, *n.Value)
+                }
+        }
+
+        for _, child := range n.Children {
+                validateNode(child, path, errs)
+        }
+}
+
+// newConstraintError returns a ParseError for a value that doesn't
+// match its constraint.
+func newConstraintError(path []string, configNode *configmodel.VyOSConfigNode, value string) *ParseError {
+        message := fmt.Sprintf("Invalid value %q for %q", value, configNode.Name)
+        if configNode.Constraint.ErrorMessage != "" {
+                message = message + ": " + configNode.Constraint.ErrorMessage
+        }
+        pe := newParseError(0, 0, path, message)
+        pe.Token = value
+        return pe
+}
+
+// checkConstraint returns true if `value` is allowed by `c`.
+func checkConstraint(c *configmodel.Constraint, value string) bool {
+        if c == nil {
+                return true
+        }
+
+        unknown := false
+        for _, regex := range c.Regex {
+                re := compileConstraintRegex(regex)
+                if re == nil {
+                        unknown = true
+                        continue
+                }
+                if re.MatchString(value) {
+                        return true
+                }
+        }
+        for _, v := range c.Validators {
+                f := lookupValidator(v.Name)
+                if f == nil {
+                        unknown = true
+                        continue
+                }
+                if f(value, v.Argument) == nil {
+                        return true
+                }
+        }
+        return unknown
+}
+
+// compileConstraintRegex compiles a regex from a constraint, anchored
+// at both ends the same way that VyOS does.  It returns nil for
+// regexes that Go's regexp package doesn't support.
+func compileConstraintRegex(regex string) *regexp.Regexp {
+        if re, ok := regexCache.Load(regex); ok {
+                return re.(*regexp.Regexp)
+        }
+        re, err := regexp.Compile("^(?:" + regex + ")$")
+        if err != nil {
+                re = nil
+        }
+        regexCache.Store(regex, re)
+        return re
+}
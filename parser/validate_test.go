@@ -0,0 +1,217 @@
+package parser
+
+import (
+        "encoding/xml"
+        "errors"
+        "slices"
+        "testing"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// validateTestXML is a trimmed-down interface definition with a few
+// different kinds of constraints.
+const validateTestXML = `<?xml version="1.0"?>
+<interfaceDefinition>
+  <node name="interfaces">
+    <children>
+      <tagNode name="ethernet">
+        <properties>
+          <help>Ethernet Interface</help>
+          <constraint>
+            <regex>((eth|lan)[0-9]+|(eno|ens|enp|enx).+)</regex>
+          </constraint>
+          <constraintErrorMessage>Invalid Ethernet interface name</constraintErrorMessage>
+        </properties>
+        <children>
+          <leafNode name="mtu">
+            <properties>
+              <constraint>
+                <validator name="numeric" argument="--range 68-16000"/>
+              </constraint>
+              <constraintErrorMessage>MTU must be between 68 and 16000</constraintErrorMessage>
+            </properties>
+          </leafNode>
+          <leafNode name="duplex">
+            <properties>
+              <constraint>
+                <regex>(auto|half|full)</regex>
+              </constraint>
+            </properties>
+          </leafNode>
+          <leafNode name="hw-id">
+            <properties>
+              <constraint>
+                <validator name="not-a-real-validator"/>
+              </constraint>
+            </properties>
+          </leafNode>
+          <leafNode name="description"/>
+        </children>
+      </tagNode>
+    </children>
+  </node>
+</interfaceDefinition>
+`
+
+// getValidateTestModel returns the config model from validateTestXML.
+func getValidateTestModel(t *testing.T) *configmodel.VyOSConfigNode {
+        id := &configmodel.InterfaceDefinition{}
+        if err := xml.Unmarshal([]byte(validateTestXML), id); err != nil {
+                t.Fatalf("Failed to parse XML: %v", err)
+        }
+        return id.VyOSConfig()
+}
+
+func TestConstraintModel(t *testing.T) {
+        configModel := getValidateTestModel(t)
+        ethernet := configModel.FindNodeByName("interfaces").FindNodeByName("ethernet")
+        if ethernet.Constraint == nil || !slices.Equal(ethernet.Constraint.Regex, []string{"((eth|lan)[0-9]+|(eno|ens|enp|enx).+)"}) {
+                t.Fatalf("Unexpected ethernet constraint: %+v", ethernet.Constraint)
+        }
+        if ethernet.Constraint.ErrorMessage != "Invalid Ethernet interface name" {
+                t.Errorf("Unexpected error message %q", ethernet.Constraint.ErrorMessage)
+        }
+
+        mtu := ethernet.FindNodeByName("mtu")
+        if mtu.Constraint == nil || len(mtu.Constraint.Validators) != 1 || mtu.Constraint.Validators[0].Name != "numeric" || mtu.Constraint.Validators[0].Argument != "--range 68-16000" {
+                t.Errorf("Unexpected mtu constraint: %+v", mtu.Constraint)
+        }
+        if ethernet.FindNodeByName("description").Constraint != nil {
+                t.Errorf("Expected no constraint for description")
+        }
+}
+
+func TestValidate(t *testing.T) {
+        configModel := getValidateTestModel(t)
+
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 mtu '1500'
+set interfaces ethernet eth0 duplex 'full'
+set interfaces ethernet eth0 hw-id 'anything'
+set interfaces ethernet eth0 description 'anything goes'
+set interfaces ethernet enp3s0 mtu '9000'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        if err := Validate(ast); err != nil {
+                t.Errorf("Expected a valid config, got %v", err)
+        }
+
+        ast, err = ParseSetFormat(`set interfaces ethernet eth0 mtu 'banana'
+set interfaces ethernet eth0 duplex 'fullish'
+set interfaces ethernet foo0 mtu '20000'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        err = Validate(ast)
+        var pes ParseErrors
+        if !errors.As(err, &pes) {
+                t.Fatalf("Expected ParseErrors, got %v", err)
+        }
+
+        want := []string{
+                `Invalid value "banana" for "mtu": MTU must be between 68 and 16000 (under "interfaces ethernet eth0 mtu")`,
+                `Invalid value "fullish" for "duplex" (under "interfaces ethernet eth0 duplex")`,
+                `Invalid value "foo0" for "ethernet": Invalid Ethernet interface name (under "interfaces ethernet")`,
+                `Invalid value "20000" for "mtu": MTU must be between 68 and 16000 (under "interfaces ethernet foo0 mtu")`,
+        }
+        if len(pes) != len(want) {
+                t.Fatalf("Got %d errors, want %d: %v", len(pes), len(want), err)
+        }
+        for i := range want {
+                if pes[i].Error() != want[i] {
+                        t.Errorf("Error %d is %q, want %q", i, pes[i].Error(), want[i])
+                }
+        }
+        if pes[0].Token != "banana" {
+                t.Errorf("Token is %q, want banana", pes[0].Token)
+        }
+}
+
+func TestRegisterValidator(t *testing.T) {
+        configModel := getValidateTestModel(t)
+        ast, err := ParseSetFormat("set interfaces ethernet eth0 hw-id 'not-a-mac'\n", configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+
+        // Unregistered validators can't be checked, so they pass.
+        if err := Validate(ast); err != nil {
+                t.Errorf("Expected a valid config, got %v", err)
+        }
+
+        RegisterValidator("not-a-real-validator", func(value, argument string) error {
+                return errors.New("never valid")
+        })
+        defer func() {
+                validatorsMu.Lock()
+                delete(validators, "not-a-real-validator")
+                validatorsMu.Unlock()
+        }()
+        if err := Validate(ast); err == nil {
+                t.Errorf("Expected an error from the registered validator")
+        }
+}
+
+func TestCheckConstraint(t *testing.T) {
+        c := &configmodel.Constraint{
+                Regex:      []string{"(auto|half|full)"},
+                Validators: []*configmodel.Validator{{Name: "numeric", Argument: "--range 10-20"}},
+        }
+        tests := []struct {
+                value string
+                valid bool
+        }{
+                {"full", true},
+                {"fullish", false},
+                {"15", true},
+                {"25", false},
+        }
+        for _, test := range tests {
+                if got := checkConstraint(c, test.value); got != test.valid {
+                        t.Errorf("checkConstraint(%q) = %v, want %v", test.value, got, test.valid)
+                }
+        }
+
+        if !checkConstraint(nil, "anything") {
+                t.Errorf("Expected a nil constraint to allow anything")
+        }
+        // Constraints that can't be checked are assumed to pass.
+        unknown := &configmodel.Constraint{Regex: []string{"(?!lookahead)"}}
+        if !checkConstraint(unknown, "anything") {
+                t.Errorf("Expected an unsupported regex to pass")
+        }
+}
+
+// getConstrainedNode returns the node at `path` in the default config
+// model, skipping the test if the embedded model was generated before
+// constraints were added to it.
+func getConstrainedNode(t *testing.T, configModel *configmodel.VyOSConfigNode, path ...string) *configmodel.VyOSConfigNode {
+        n := getConfigNode(t, configModel, path...)
+        if n.Constraint == nil {
+                t.Skipf("Embedded config model has no constraint for %v; regenerate syntax/*.json.gz", path)
+        }
+        return n
+}
+
+func TestValidateDefaultModel(t *testing.T) {
+        configModel := getConfigModel(t)
+        getConstrainedNode(t, configModel, "interfaces", "ethernet", "mtu")
+
+        ast, err := ParseSetFormat(`set interfaces ethernet eth0 mtu '1500'
+set interfaces ethernet eth1 mtu 'banana'
+`, configModel)
+        if err != nil {
+                t.Fatalf("Failed to parse: %v", err)
+        }
+        err = Validate(ast)
+        var pes ParseErrors
+        if !errors.As(err, &pes) || len(pes) != 1 {
+                t.Fatalf("Expected 1 ParseError, got %v", err)
+        }
+        if want := []string{"interfaces", "ethernet", "eth1", "mtu"}; pes[0].Token != "banana" || !slices.Equal(pes[0].Path, want) {
+                t.Errorf("Error is %v, want %q under %v", pes[0], "banana", want)
+        }
+}
@@ -0,0 +1,599 @@
+package parser
+
+import (
+        "encoding/base64"
+        "fmt"
+        "math"
+        "net/netip"
+        "net/url"
+        "regexp"
+        "strconv"
+        "strings"
+)
+
+// validators holds the Go versions of the external validators that
+// VyOS's interface definitions refer to, keyed by the name used in
+// `<validator name="..."/>`.  They follow the behavior of the scripts
+// in vyos-1x's src/validators and vyos-utils, except that nothing
+// here looks at the running system; for instance, `interface-name`
+// only checks the name's format, and `port-range` accepts anything
+// that looks like a service name.
+var validators = map[string]ValidatorFunc{
+        "numeric": validateNumeric,
+
+        "ip-address":           anyOf(ipv4Address, ipv6Address),
+        "ip-cidr":              anyOf(ipv4CIDR, ipv6CIDR),
+        "ip-host":              anyOf(ipv4Host, ipv6Host),
+        "ip-prefix":            anyOf(ipv4Prefix, ipv6Prefix),
+        "ipv4":                 anyOf(ipv4Address, ipv4CIDR),
+        "ipv4-address":         ipv4Address,
+        "ipv4-address-exclude": exclude(ipv4Address),
+        "ipv4-host":            ipv4Host,
+        "ipv4-multicast":       ipv4Multicast,
+        "ipv4-prefix":          ipv4Prefix,
+        "ipv4-prefix-exclude":  exclude(ipv4Prefix),
+        "ipv4-range":           ipv4Range,
+        "ipv4-range-exclude":   exclude(ipv4Range),
+        "ipv6":                 anyOf(ipv6Address, ipv6CIDR),
+        "ipv6-address":         ipv6Address,
+        "ipv6-address-exclude": exclude(ipv6Address),
+        "ipv6-host":            ipv6Host,
+        "ipv6-link-local":      ipv6LinkLocal,
+        "ipv6-multicast":       ipv6Multicast,
+        "ipv6-prefix":          ipv6Prefix,
+        "ipv6-prefix-exclude":  exclude(ipv6Prefix),
+        "ipv6-range":           ipv6Range,
+        "ipv6-range-exclude":   exclude(ipv6Range),
+
+        "mac-address":          validateMACAddress,
+        "mac-address-exclude":  exclude(validateMACAddress),
+        "mac-address-firewall": anyOf(validateMACAddress, exclude(validateMACAddress)),
+
+        "fqdn":           validateFQDN,
+        "interface-name": validateInterfaceName,
+        "port-range":     validatePortRange,
+        "port-multi":     validatePortMulti,
+        "ip-protocol":    validateIPProtocol,
+        "tcp-flag":       validateTCPFlag,
+        "ether-type":     validateEtherType,
+        "base64":         validateBase64,
+        "url":            validateURL,
+
+        "bgp-extended-community": validateBGPExtendedCommunity,
+        "bgp-rd-rt":              validateBGPExtendedCommunity,
+        "bgp-large-community":    validateBGPLargeCommunity,
+        "as-number-list":         validateASNumberList,
+}
+
+// anyOf returns a validator that accepts anything accepted by at least
+// one of `funcs`.  The error from the first function is returned when
+// none of them match.
+func anyOf(funcs ...ValidatorFunc) ValidatorFunc {
+        return func(value, argument string) error {
+                var first error
+                for _, f := range funcs {
+                        err := f(value, argument)
+                        if err == nil {
+                                return nil
+                        }
+                        if first == nil {
+                                first = err
+                        }
+                }
+                return first
+        }
+}
+
+// exclude returns a validator for VyOS's `*-exclude` validators,
+// which accept a value matching `f` with a leading `!`.
+func exclude(f ValidatorFunc) ValidatorFunc {
+        return func(value, argument string) error {
+                if !strings.HasPrefix(value, "!") {
+                        return fmt.Errorf("%q does not start with '!'", value)
+                }
+                return f(value[1:], argument)
+        }
+}
+
+// parseIPAddress parses a single IPv4 or IPv6 address with no prefix
+// length and no zone.
+func parseIPAddress(value string, is4 bool) (netip.Addr, error) {
+        addr, err := netip.ParseAddr(value)
+        if err != nil || addr.Is4() != is4 || addr.Zone() != "" {
+                return netip.Addr{}, fmt.Errorf("%q is not a valid IPv%s address", value, ipVersion(is4))
+        }
+        return addr, nil
+}
+
+// parseIPPrefix parses an IPv4 or IPv6 address with a prefix length,
+// like 192.0.2.1/24.
+func parseIPPrefix(value string, is4 bool) (netip.Prefix, error) {
+        prefix, err := netip.ParsePrefix(value)
+        if err != nil || prefix.Addr().Is4() != is4 {
+                return netip.Prefix{}, fmt.Errorf("%q is not a valid IPv%s address with a prefix length", value, ipVersion(is4))
+        }
+        return prefix, nil
+}
+
+func ipVersion(is4 bool) string {
+        if is4 {
+                return "4"
+        }
+        return "6"
+}
+
+// ipAddressValidator accepts a single address.
+func ipAddressValidator(is4 bool) ValidatorFunc {
+        return func(value, argument string) error {
+                _, err := parseIPAddress(value, is4)
+                return err
+        }
+}
+
+// ipCIDRValidator accepts any address with a prefix length.
+func ipCIDRValidator(is4 bool) ValidatorFunc {
+        return func(value, argument string) error {
+                _, err := parseIPPrefix(value, is4)
+                return err
+        }
+}
+
+// ipHostValidator accepts an interface address with a prefix length,
+// like 192.0.2.1/24.  The address can't be the network address, or
+// for IPv4 the broadcast address, unless the prefix is too long to
+// have them (/31 and /32 for IPv4, /127 and /128 for IPv6).
+func ipHostValidator(is4 bool) ValidatorFunc {
+        return func(value, argument string) error {
+                prefix, err := parseIPPrefix(value, is4)
+                if err != nil {
+                        return err
+                }
+                addr := prefix.Addr()
+                if prefix.Bits() >= addr.BitLen()-1 {
+                        return nil
+                }
+                if addr == prefix.Masked().Addr() {
+                        return fmt.Errorf("%q is a network address, not a host address", value)
+                }
+                if is4 && addr == lastAddr(prefix) {
+                        return fmt.Errorf("%q is a broadcast address, not a host address", value)
+                }
+                return nil
+        }
+}
+
+// ipPrefixValidator accepts a network prefix, like 192.0.2.0/24,
+// with no host bits set.
+func ipPrefixValidator(is4 bool) ValidatorFunc {
+        return func(value, argument string) error {
+                prefix, err := parseIPPrefix(value, is4)
+                if err != nil {
+                        return err
+                }
+                if prefix.Addr() != prefix.Masked().Addr() {
+                        return fmt.Errorf("%q has host bits set; did you mean %s?", value, prefix.Masked())
+                }
+                return nil
+        }
+}
+
+// ipRangeValidator accepts a range of addresses like
+// 192.0.2.10-192.0.2.20, where the start isn't after the end.
+func ipRangeValidator(is4 bool) ValidatorFunc {
+        return func(value, argument string) error {
+                start, end, ok := strings.Cut(value, "-")
+                if !ok {
+                        return fmt.Errorf("%q is not an address range", value)
+                }
+                startAddr, err := parseIPAddress(start, is4)
+                if err != nil {
+                        return err
+                }
+                endAddr, err := parseIPAddress(end, is4)
+                if err != nil {
+                        return err
+                }
+                if endAddr.Less(startAddr) {
+                        return fmt.Errorf("%q starts after it ends", value)
+                }
+                return nil
+        }
+}
+
+// lastAddr returns the highest address in `prefix`.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+        b := prefix.Masked().Addr().AsSlice()
+        for i := prefix.Bits(); i < len(b)*8; i++ {
+                b[i/8] |= 0x80 >> (i % 8)
+        }
+        addr, _ := netip.AddrFromSlice(b)
+        return addr
+}
+
+var (
+        ipv4Address = ipAddressValidator(true)
+        ipv4CIDR    = ipCIDRValidator(true)
+        ipv4Host    = ipHostValidator(true)
+        ipv4Prefix  = ipPrefixValidator(true)
+        ipv4Range   = ipRangeValidator(true)
+        ipv6Address = ipAddressValidator(false)
+        ipv6CIDR    = ipCIDRValidator(false)
+        ipv6Host    = ipHostValidator(false)
+        ipv6Prefix  = ipPrefixValidator(false)
+        ipv6Range   = ipRangeValidator(false)
+)
+
+func ipv4Multicast(value, argument string) error {
+        addr, err := parseIPAddress(value, true)
+        if err != nil {
+                return err
+        }
+        if !addr.IsMulticast() {
+                return fmt.Errorf("%q is not a multicast address", value)
+        }
+        return nil
+}
+
+func ipv6Multicast(value, argument string) error {
+        addr, err := parseIPAddress(value, false)
+        if err != nil {
+                return err
+        }
+        if !addr.IsMulticast() {
+                return fmt.Errorf("%q is not a multicast address", value)
+        }
+        return nil
+}
+
+func ipv6LinkLocal(value, argument string) error {
+        addr, err := parseIPAddress(value, false)
+        if err != nil {
+                return err
+        }
+        if !addr.IsLinkLocalUnicast() {
+                return fmt.Errorf("%q is not a link-local address", value)
+        }
+        return nil
+}
+
+var (
+        macAddressRE    = regexp.MustCompile(`^[0-9a-fA-F]{2}(:[0-9a-fA-F]{2}){5}$`)
+        fqdnLabelRE     = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9]{0,61}[a-zA-Z0-9])?$`)
+        interfaceNameRE = regexp.MustCompile(`^((bond|br|dum|eth|gnv|ifb|ipoe|l2tp|l2tpeth|lan|macsec|peth|pppoe|pptp|sstpc|tun|veth|vti|vtun|vxlan|wg|wlan|wwan)[0-9]+|(eno|ens|enp|enx).+|lo)(\.[0-9]+){0,2}$`)
+        serviceNameRE   = regexp.MustCompile(`^[a-zA-Z][-a-zA-Z0-9]*$`)
+)
+
+// validateMACAddress accepts a MAC address like 00:53:00:11:22:33.
+func validateMACAddress(value, argument string) error {
+        if !macAddressRE.MatchString(value) {
+                return fmt.Errorf("%q is not a valid MAC address", value)
+        }
+        return nil
+}
+
+// validateFQDN accepts a fully-qualified domain name, with or without
+// a trailing dot.
+func validateFQDN(value, argument string) error {
+        name := strings.TrimSuffix(value, ".")
+        if name == "" || len(name) > 253 {
+                return fmt.Errorf("%q is not a valid domain name", value)
+        }
+        for _, label := range strings.Split(name, ".") {
+                if !fqdnLabelRE.MatchString(label) {
+                        return fmt.Errorf("%q is not a valid domain name", value)
+                }
+        }
+        return nil
+}
+
+// validateInterfaceName accepts any name that VyOS could give to an
+// interface, including VLAN subinterfaces like eth0.10.
+func validateInterfaceName(value, argument string) error {
+        if !interfaceNameRE.MatchString(value) {
+                return fmt.Errorf("%q is not a valid interface name", value)
+        }
+        return nil
+}
+
+// parsePort parses a port number or service name.
+func parsePort(value string) (int, error) {
+        if serviceNameRE.MatchString(value) {
+                return 0, nil
+        }
+        port, err := strconv.Atoi(value)
+        if err != nil || port < 1 || port > 65535 {
+                return 0, fmt.Errorf("%q is not a valid port number", value)
+        }
+        return port, nil
+}
+
+// validatePortRange accepts a port number, a service name like
+// `http`, or a range of ports like `1000-2000`.
+func validatePortRange(value, argument string) error {
+        start, end, ok := strings.Cut(value, "-")
+        if !ok || serviceNameRE.MatchString(value) {
+                _, err := parsePort(value)
+                return err
+        }
+        startPort, err := strconv.Atoi(start)
+        if err != nil || startPort < 1 || startPort > 65535 {
+                return fmt.Errorf("%q is not a valid port range", value)
+        }
+        endPort, err := strconv.Atoi(end)
+        if err != nil || endPort < 1 || endPort > 65535 {
+                return fmt.Errorf("%q is not a valid port range", value)
+        }
+        if endPort < startPort {
+                return fmt.Errorf("%q starts after it ends", value)
+        }
+        return nil
+}
+
+// validatePortMulti accepts a comma-separated list of anything that
+// validatePortRange accepts, like `22,80,8000-8080`.
+func validatePortMulti(value, argument string) error {
+        for _, port := range strings.Split(value, ",") {
+                if err := validatePortRange(port, argument); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// ipProtocols lists the protocol names that VyOS accepts in addition
+// to protocol numbers.
+var ipProtocols = map[string]bool{
+        "all": true, "tcp_udp": true,
+        "ip": true, "hopopt": true, "icmp": true, "igmp": true, "ggp": true,
+        "ipencap": true, "st": true, "tcp": true, "egp": true, "igp": true,
+        "pup": true, "udp": true, "hmp": true, "xns-idp": true, "rdp": true,
+        "iso-tp4": true, "dccp": true, "xtp": true, "ddp": true, "idpr-cmtp": true,
+        "ipv6": true, "ipv6-route": true, "ipv6-frag": true, "idrp": true,
+        "rsvp": true, "gre": true, "esp": true, "ah": true, "skip": true,
+        "ipv6-icmp": true, "icmpv6": true, "ipv6-nonxt": true, "ipv6-opts": true,
+        "rspf": true, "vmtp": true, "eigrp": true, "ospf": true, "ax.25": true,
+        "ipip": true, "etherip": true, "encap": true, "pim": true, "ipcomp": true,
+        "vrrp": true, "l2tp": true, "isis": true, "sctp": true, "fc": true,
+        "mobility-header": true, "udplite": true, "mpls-in-ip": true,
+        "manet": true, "hip": true, "shim6": true, "wesp": true, "rohc": true,
+}
+
+// validateIPProtocol accepts an IP protocol name or number, optionally
+// negated with a leading `!`.
+func validateIPProtocol(value, argument string) error {
+        protocol := strings.TrimPrefix(value, "!")
+        if ipProtocols[protocol] {
+                return nil
+        }
+        n, err := strconv.Atoi(protocol)
+        if err != nil || n < 0 || n > 255 {
+                return fmt.Errorf("%q is not a valid IP protocol", value)
+        }
+        return nil
+}
+
+// validateTCPFlag accepts a TCP flag name, optionally negated with a
+// leading `!`.
+func validateTCPFlag(value, argument string) error {
+        switch strings.TrimPrefix(value, "!") {
+        case "syn", "ack", "rst", "fin", "urg", "psh", "ecn", "cwr":
+                return nil
+        }
+        return fmt.Errorf("%q is not a valid TCP flag", value)
+}
+
+// validateEtherType accepts an Ethernet type name, or a number from
+// 0x0600 (1536) through 0xffff in decimal or hex.
+func validateEtherType(value, argument string) error {
+        switch value {
+        case "802.1Q", "802.1ad", "arp", "ipv4", "ipv6", "mpls":
+                return nil
+        }
+        n, err := strconv.ParseUint(value, 0, 16)
+        if err != nil || n < 0x0600 {
+                return fmt.Errorf("%q is not a valid Ethernet type", value)
+        }
+        return nil
+}
+
+// validateBase64 accepts base64-encoded data.  The argument
+// `--decoded-len N` requires the decoded data to be exactly N bytes
+// long, as with WireGuard keys.
+func validateBase64(value, argument string) error {
+        decoded, err := base64.StdEncoding.DecodeString(value)
+        if err != nil {
+                return fmt.Errorf("%q is not valid base64", value)
+        }
+        if length, ok := strings.CutPrefix(argument, "--decoded-len "); ok {
+                n, err := strconv.Atoi(strings.TrimSpace(length))
+                if err != nil {
+                        return fmt.Errorf("Invalid validator argument %q", argument)
+                }
+                if len(decoded) != n {
+                        return fmt.Errorf("%q decodes to %d bytes, not %d", value, len(decoded), n)
+                }
+        }
+        return nil
+}
+
+// validateURL accepts an absolute URL with a scheme and host.
+func validateURL(value, argument string) error {
+        u, err := url.Parse(value)
+        if err != nil || u.Scheme == "" || u.Host == "" {
+                return fmt.Errorf("%q is not a valid URL", value)
+        }
+        return nil
+}
+
+// parseASN parses a 32-bit AS number.
+func parseASN(value string) (uint64, error) {
+        n, err := strconv.ParseUint(value, 10, 32)
+        if err != nil || n == 0 {
+                return 0, fmt.Errorf("%q is not a valid AS number", value)
+        }
+        return n, nil
+}
+
+// validateASNumberList accepts a space-separated list of AS numbers.
+func validateASNumberList(value, argument string) error {
+        for _, asn := range strings.Fields(value) {
+                if _, err := parseASN(asn); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// validateBGPExtendedCommunity accepts a route distinguisher or route
+// target, either `ASN:NN` or `IP:NN`.  A 4-byte ASN or an IP address
+// leaves room for only a 16-bit value.
+func validateBGPExtendedCommunity(value, argument string) error {
+        admin, assigned, ok := strings.Cut(value, ":")
+        if !ok {
+                return fmt.Errorf("%q is not a valid extended community", value)
+        }
+
+        maxAssigned := uint64(math.MaxUint16)
+        if asn, err := parseASN(admin); err == nil {
+                if asn <= math.MaxUint16 {
+                        maxAssigned = math.MaxUint32
+                }
+        } else if _, err := parseIPAddress(admin, true); err != nil {
+                return fmt.Errorf("%q is not a valid extended community", value)
+        }
+
+        n, err := strconv.ParseUint(assigned, 10, 32)
+        if err != nil || n > maxAssigned {
+                return fmt.Errorf("%q is not a valid extended community", value)
+        }
+        return nil
+}
+
+// validateBGPLargeCommunity accepts a large community like
+// `65000:1:2`, made up of three 32-bit numbers.
+func validateBGPLargeCommunity(value, argument string) error {
+        parts := strings.Split(value, ":")
+        if len(parts) != 3 {
+                return fmt.Errorf("%q is not a valid large community", value)
+        }
+        for _, part := range parts {
+                if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+                        return fmt.Errorf("%q is not a valid large community", value)
+                }
+        }
+        return nil
+}
+
+// validateNumeric implements VyOS's `numeric` validator, which
+// accepts these options:
+//
+//      --non-negative   The value must be >= 0
+//      --positive       The value must be > 0
+//      --allow-float    Allow non-integer values
+//      --relative       Allow a leading `+` or `-`
+//      --range a-b      The value must be between a and b, inclusive
+//      --not-range a-b  The value must not be between a and b
+//
+// If there's more than one `--range`, then the value only needs to
+// be in one of them.
+func validateNumeric(value, argument string) error {
+        allowFloat, nonNegative, positive, relative := false, false, false, false
+        ranges := [][2]float64{}
+        notRanges := [][2]float64{}
+
+        args := strings.Fields(argument)
+        for i := 0; i < len(args); i++ {
+                switch args[i] {
+                case "--allow-float":
+                        allowFloat = true
+                case "--non-negative":
+                        nonNegative = true
+                case "--positive":
+                        positive = true
+                case "--relative":
+                        relative = true
+                case "--range", "--not-range":
+                        if i+1 >= len(args) {
+                                return fmt.Errorf("Missing argument for %s", args[i])
+                        }
+                        r, err := parseNumericRange(args[i+1])
+                        if err != nil {
+                                return err
+                        }
+                        if args[i] == "--range" {
+                                ranges = append(ranges, r)
+                        } else {
+                                notRanges = append(notRanges, r)
+                        }
+                        i++
+                }
+        }
+
+        number := value
+        if relative && (strings.HasPrefix(number, "+") || strings.HasPrefix(number, "-")) {
+                number = number[1:]
+        } else if strings.HasPrefix(number, "+") {
+                // strconv accepts a leading `+`, but VyOS doesn't.
+                return fmt.Errorf("%q is not a valid number", value)
+        }
+
+        var n float64
+        if allowFloat {
+                f, err := strconv.ParseFloat(number, 64)
+                if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+                        return fmt.Errorf("%q is not a valid number", value)
+                }
+                n = f
+        } else {
+                i, err := strconv.ParseInt(number, 10, 64)
+                if err != nil {
+                        return fmt.Errorf("%q is not a valid integer", value)
+                }
+                n = float64(i)
+        }
+
+        if nonNegative && n < 0 {
+                return fmt.Errorf("%q is negative", value)
+        }
+        if positive && n <= 0 {
+                return fmt.Errorf("%q is not positive", value)
+        }
+        if len(ranges) > 0 {
+                inRange := false
+                for _, r := range ranges {
+                        if n >= r[0] && n <= r[1] {
+                                inRange = true
+                        }
+                }
+                if !inRange {
+                        return fmt.Errorf("%q is not in range %s", value, argument)
+                }
+        }
+        for _, r := range notRanges {
+                if n >= r[0] && n <= r[1] {
+                        return fmt.Errorf("%q is in excluded range %g-%g", value, r[0], r[1])
+                }
+        }
+        return nil
+}
+
+// parseNumericRange parses a range like `1-65535`.
+func parseNumericRange(s string) ([2]float64, error) {
+        // Allow negative numbers by looking for the separator after
+        // the first character.
+        sep := strings.Index(s[min(1, len(s)):], "-")
+        if sep < 0 {
+                return [2]float64{}, fmt.Errorf("Invalid range %q", s)
+        }
+        sep += min(1, len(s))
+
+        low, err := strconv.ParseFloat(s[:sep], 64)
+        if err != nil {
+                return [2]float64{}, fmt.Errorf("Invalid range %q", s)
+        }
+        high, err := strconv.ParseFloat(s[sep+1:], 64)
+        if err != nil {
+                return [2]float64{}, fmt.Errorf("Invalid range %q", s)
+        }
+        return [2]float64{low, high}, nil
+}
@@ -0,0 +1,187 @@
+package parser
+
+import (
+        "os"
+        "strings"
+        "testing"
+
+        "github.com/scottlaird/vyos-parser/configmodel"
+        "github.com/scottlaird/vyos-parser/syntax"
+)
+
+// TestValidatorNames makes sure that every validator used by vyos-1x
+// is registered: the ones listed in testdata/validators.txt, and any
+// others that the embedded config models use.
+func TestValidatorNames(t *testing.T) {
+        names := map[string]bool{}
+        b, err := os.ReadFile("testdata/validators.txt")
+        if err != nil {
+                t.Fatalf("Failed to open testdata file: %v", err)
+        }
+        for _, line := range strings.Split(string(b), "\n") {
+                if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+                        names[line] = true
+                }
+        }
+
+        var walk func(n *configmodel.VyOSConfigNode)
+        walk = func(n *configmodel.VyOSConfigNode) {
+                if n.Constraint != nil {
+                        for _, v := range n.Constraint.Validators {
+                                names[v.Name] = true
+                        }
+                }
+                for _, child := range n.Children {
+                        walk(child)
+                }
+        }
+        versions, err := syntax.ListVersions()
+        if err != nil {
+                t.Fatalf("Failed to list config models: %v", err)
+        }
+        for _, version := range versions {
+                configModel, err := syntax.GetConfigModel(version)
+                if err != nil {
+                        t.Fatalf("Failed to open config model %q: %v", version, err)
+                }
+                walk(configModel)
+        }
+
+        for name := range names {
+                if lookupValidator(name) == nil {
+                        t.Errorf("No validator registered for %q", name)
+                }
+        }
+}
+
+func TestValidators(t *testing.T) {
+        tests := []struct {
+                name     string
+                argument string
+                value    string
+                valid    bool
+        }{
+                {"numeric", "--range 68-16000", "1500", true},
+                {"numeric", "--range 68-16000", "68", true},
+                {"numeric", "--range 68-16000", "67", false},
+                {"numeric", "--range 1-2", "1.5", false},
+                {"numeric", "--allow-float --range 1-2", "1.5", true},
+                {"numeric", "--positive", "0", false},
+                {"numeric", "--non-negative", "0", true},
+                {"numeric", "--non-negative", "-1", false},
+                {"numeric", "--relative", "+5", true},
+                {"numeric", "", "+5", false},
+                {"numeric", "--range 1-3 --range 5-10", "5", true},
+                {"numeric", "--range 1-3 --range 5-10", "4", false},
+                {"numeric", "--range -10--1", "-5", true},
+                {"numeric", "--range 1-1000 --not-range 100-199", "100", false},
+                {"numeric", "", "banana", false},
+
+                {"ipv4-address", "", "192.0.2.1", true},
+                {"ipv4-address", "", "192.0.2.1/24", false},
+                {"ipv4-address", "", "2001:db8::1", false},
+                {"ipv4-address", "", "192.0.2.256", false},
+                {"ipv4-host", "", "192.0.2.1/24", true},
+                {"ipv4-host", "", "192.0.2.0/24", false},
+                {"ipv4-host", "", "192.0.2.255/24", false},
+                {"ipv4-host", "", "192.0.2.0/31", true},
+                {"ipv4-host", "", "192.0.2.1", false},
+                {"ipv4-prefix", "", "192.0.2.0/24", true},
+                {"ipv4-prefix", "", "192.0.2.1/24", false},
+                {"ipv4-prefix-exclude", "", "!192.0.2.0/24", true},
+                {"ipv4-prefix-exclude", "", "192.0.2.0/24", false},
+                {"ipv4", "", "192.0.2.1", true},
+                {"ipv4", "", "192.0.2.1/24", true},
+                {"ipv4-multicast", "", "224.0.0.5", true},
+                {"ipv4-multicast", "", "192.0.2.1", false},
+                {"ipv4-range", "", "192.0.2.10-192.0.2.20", true},
+                {"ipv4-range", "", "192.0.2.20-192.0.2.10", false},
+                {"ipv6-address", "", "2001:db8::1", true},
+                {"ipv6-address", "", "fe80::1%eth0", false},
+                {"ipv6-host", "", "2001:db8::1/64", true},
+                {"ipv6-host", "", "2001:db8::/64", false},
+                {"ipv6-prefix", "", "2001:db8::/32", true},
+                {"ipv6-prefix", "", "2001:db8::1/32", false},
+                {"ipv6-link-local", "", "fe80::1", true},
+                {"ipv6-link-local", "", "2001:db8::1", false},
+                {"ip-address", "", "192.0.2.1", true},
+                {"ip-address", "", "2001:db8::1", true},
+                {"ip-address", "", "router1", false},
+                {"ip-host", "", "2001:db8::1/64", true},
+                {"ip-prefix", "", "10.0.0.0/8", true},
+
+                {"mac-address", "", "00:53:00:11:22:aa", true},
+                {"mac-address", "", "00:53:00:11:22", false},
+                {"mac-address", "", "0053.0011.22aa", false},
+                {"mac-address-exclude", "", "!00:53:00:11:22:aa", true},
+                {"mac-address-firewall", "", "!00:53:00:11:22:aa", true},
+                {"mac-address-firewall", "", "00:53:00:11:22:aa", true},
+
+                {"fqdn", "", "router1.example.com", true},
+                {"fqdn", "", "router1.example.com.", true},
+                {"fqdn", "", "router1", true},
+                {"fqdn", "", "-router1.example.com", false},
+                {"fqdn", "", "router1..example.com", false},
+                {"fqdn", "", "router_1.example.com", false},
+
+                {"interface-name", "", "eth0", true},
+                {"interface-name", "", "eth0.10", true},
+                {"interface-name", "", "eth0.10.20", true},
+                {"interface-name", "", "enp3s0f1", true},
+                {"interface-name", "", "wg0", true},
+                {"interface-name", "", "lo", true},
+                {"interface-name", "", "bogus0", false},
+                {"interface-name", "", "eth", false},
+
+                {"port-range", "", "22", true},
+                {"port-range", "", "ssh", true},
+                {"port-range", "", "ftp-data", true},
+                {"port-range", "", "1000-2000", true},
+                {"port-range", "", "2000-1000", false},
+                {"port-range", "", "0", false},
+                {"port-range", "", "65536", false},
+                {"port-multi", "", "22,80,8000-8080", true},
+                {"port-multi", "", "22,,80", false},
+
+                {"ip-protocol", "", "tcp", true},
+                {"ip-protocol", "", "!udp", true},
+                {"ip-protocol", "", "47", true},
+                {"ip-protocol", "", "256", false},
+                {"ip-protocol", "", "bogus", false},
+                {"tcp-flag", "", "syn", true},
+                {"tcp-flag", "", "!ack", true},
+                {"tcp-flag", "", "bogus", false},
+                {"ether-type", "", "0x8100", true},
+                {"ether-type", "", "2048", true},
+                {"ether-type", "", "arp", true},
+                {"ether-type", "", "100", false},
+
+                {"base64", "", "aGVsbG8=", true},
+                {"base64", "", "not base64", false},
+                {"base64", "--decoded-len 32", "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=", true},
+                {"base64", "--decoded-len 32", "aGVsbG8=", false},
+                {"url", "", "https://example.com/path", true},
+                {"url", "", "example.com", false},
+
+                {"as-number-list", "", "65000 65001", true},
+                {"as-number-list", "", "65000 banana", false},
+                {"bgp-rd-rt", "", "65000:100", true},
+                {"bgp-rd-rt", "", "192.0.2.1:100", true},
+                {"bgp-rd-rt", "", "4200000000:100", true},
+                {"bgp-rd-rt", "", "4200000000:100000", false},
+                {"bgp-rd-rt", "", "65000", false},
+                {"bgp-large-community", "", "4200000000:1:2", true},
+                {"bgp-large-community", "", "65000:1", false},
+        }
+        for _, test := range tests {
+                f := lookupValidator(test.name)
+                if f == nil {
+                        t.Errorf("No validator registered for %q", test.name)
+                        continue
+                }
+                err := f(test.value, test.argument)
+                if (err == nil) != test.valid {
+                        t.Errorf("%s %q on %q returned %v, want valid=%v", test.name, test.argument, test.value, err, test.valid)
+                }
+        }
+}
@@ -0,0 +1,98 @@
+package parser
+
+import (
+        "bufio"
+        "fmt"
+        "regexp"
+        "slices"
+        "strconv"
+        "strings"
+)
+
+// VyOS appends a footer to config.boot that records the migration
+// version of each component of the config and the release that wrote
+// it.  Current releases use `//` comments:
+//
+//      // Warning: Do not remove the following line.
+//      // vyos-config-version: "bgp@5:broadcast-relay@1:...:webproxy@2"
+//      // Release version: 1.5-rolling-202501060800
+//
+// Older releases (1.2 and earlier) wrapped the same information in
+// `/* ... */` comments, with a `vyatta-config-version` label instead.
+var (
+        componentVersionRE = regexp.MustCompile(`^(?://|/\*\s*===)\s*(?:vyos|vyatta)-config-version:\s*"([^"]*)"`)
+        releaseVersionRE   = regexp.MustCompile(`^(?://|/\*)\s*Release version:\s*(.*?)\s*(?:\*/)?$`)
+)
+
+// parseVersionFooter looks for VyOS's version footer in `config` and
+// records what it finds in `ast`.
+func parseVersionFooter(ast *VyOSConfigAST, config string) error {
+        scanner := bufio.NewScanner(strings.NewReader(config))
+        lineno := 0
+
+        for scanner.Scan() {
+                line := strings.TrimSpace(scanner.Text())
+                lineno++
+
+                if m := componentVersionRE.FindStringSubmatch(line); m != nil {
+                        versions, err := parseComponentVersions(m[1])
+                        if err != nil {
+                                return newParseError(lineno, 1, nil, err.Error())
+                        }
+                        ast.ComponentVersions = versions
+                } else if m := releaseVersionRE.FindStringSubmatch(line); m != nil {
+                        ast.ReleaseVersion = m[1]
+                }
+        }
+
+        return scanner.Err()
+}
+
+// parseComponentVersions splits a `bgp@5:broadcast-relay@1:...`
+// string into a map of component names to versions.
+func parseComponentVersions(s string) (map[string]int, error) {
+        versions := make(map[string]int)
+        if s == "" {
+                return versions, nil
+        }
+
+        for _, component := range strings.Split(s, ":") {
+                name, v, found := strings.Cut(component, "@")
+                if !found {
+                        return nil, fmt.Errorf("Couldn't parse config version component %q", component)
+                }
+                version, err := strconv.Atoi(v)
+                if err != nil {
+                        return nil, fmt.Errorf("Couldn't parse version of config component %q: %v", component, err)
+                }
+                versions[name] = version
+        }
+        return versions, nil
+}
+
+// writeVersionFooter returns the lines of the version footer for
+// `ast`, in the same format that current VyOS releases use.  It
+// returns nothing if the AST doesn't have any version information.
+func writeVersionFooter(ast *VyOSConfigAST) []string {
+        if len(ast.ComponentVersions) == 0 && ast.ReleaseVersion == "" {
+                return nil
+        }
+
+        names := []string{}
+        for name := range ast.ComponentVersions {
+                names = append(names, name)
+        }
+        slices.Sort(names)
+
+        components := []string{}
+        for _, name := range names {
+                components = append(components, fmt.Sprintf("%s@%d", name, ast.ComponentVersions[name]))
+        }
+
+        return []string{
+                "",
+                "// Warning: Do not remove the following line.",
+                fmt.Sprintf("// vyos-config-version: %q", strings.Join(components, ":")),
+                "// Release version: " + ast.ReleaseVersion,
+        }
+}
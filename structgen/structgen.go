@@ -0,0 +1,235 @@
+// Package structgen generates Go source code for typed structs that
+// mirror a VyOS config model.  The generated structs use `vyos` tags,
+// so they can be converted to and from a parser.VyOSConfigAST with
+// parser.Unmarshal and parser.Marshal.
+package structgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/scottlaird/vyos-parser/configmodel"
+)
+
+// Options controls what Generate produces.
+type Options struct {
+	// Package is the name of the generated Go package.
+	Package string
+
+	// ModelVersion is the name of the embedded syntax model that
+	// the structs were generated from, like `vyos-20250129`.  The
+	// generated ToAST function uses it to load the model.
+	ModelVersion string
+
+	// Subtree limits generation to one part of the config, like
+	// []string{"protocols", "bgp"}.  If it's empty, then structs
+	// are generated for the whole config.
+	Subtree []string
+
+	// RootType is the name of the top-level struct.  It defaults
+	// to `Config`.
+	RootType string
+}
+
+// generator holds state while generating a single file.
+type generator struct {
+	buf       bytes.Buffer
+	typeNames map[string]string // Config path -> type name
+	usedNames map[string]bool   // Type names used so far
+}
+
+// Generate returns formatted Go source for a package of structs
+// matching `configModel`.
+func Generate(configModel *configmodel.VyOSConfigNode, options Options) ([]byte, error) {
+	if options.Package == "" {
+		return nil, fmt.Errorf("No package name specified")
+	}
+	if options.ModelVersion == "" {
+		return nil, fmt.Errorf("No model version specified")
+	}
+	rootType := options.RootType
+	if rootType == "" {
+		rootType = "Config"
+	}
+
+	g := &generator{
+		typeNames: map[string]string{},
+		usedNames: map[string]bool{rootType: true, rootType + "FromAST": true, "ModelVersion": true},
+	}
+
+	// Find the requested subtree, if any.  A tag value can't be
+	// part of the subtree, because the structs need to work for
+	// every value.
+	node := configModel
+	for _, word := range options.Subtree {
+		if node.Type == "leafnode" || node.Type == "tagnode" {
+			return nil, fmt.Errorf("Subtree %q can't continue past %s %q", strings.Join(options.Subtree, " "), node.Type, node.Name)
+		}
+		child := node.FindNodeByName(word)
+		if child == nil {
+			return nil, fmt.Errorf("Unknown word %q in subtree %q", word, strings.Join(options.Subtree, " "))
+		}
+		node = child
+	}
+
+	fmt.Fprintf(&g.buf, "// Code generated by vyos-gen-structs from %s; DO NOT EDIT.\n\n", options.ModelVersion)
+	fmt.Fprintf(&g.buf, "package %s\n\n", options.Package)
+	fmt.Fprintf(&g.buf, "import (\n\t\"github.com/scottlaird/vyos-parser/parser\"\n\t\"github.com/scottlaird/vyos-parser/syntax\"\n)\n\n")
+	fmt.Fprintf(&g.buf, "// ModelVersion is the syntax model that these types were generated from.\n")
+	fmt.Fprintf(&g.buf, "const ModelVersion = %q\n\n", options.ModelVersion)
+
+	if len(options.Subtree) == 0 {
+		if err := g.writeStruct(rootType, "the whole config", node, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		// Wrap the subtree in a root struct, so that the
+		// conversion functions can work on whole ASTs.
+		fieldType, err := g.fieldType(node, options.Subtree)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&g.buf, "// %s holds the `%s` part of the config.\n", rootType, strings.Join(options.Subtree, " "))
+		fmt.Fprintf(&g.buf, "type %s struct {\n", rootType)
+		fmt.Fprintf(&g.buf, "\t%s %s `vyos:%q`\n", goName(node.Name), fieldType, strings.Join(options.Subtree, "/"))
+		fmt.Fprintf(&g.buf, "}\n\n")
+	}
+
+	fmt.Fprintf(&g.buf, "// %sFromAST converts a parsed config into a %s.\n", rootType, rootType)
+	fmt.Fprintf(&g.buf, "func %sFromAST(ast *parser.VyOSConfigAST) (*%s, error) {\n", rootType, rootType)
+	fmt.Fprintf(&g.buf, "\tc := &%s{}\n\tif err := parser.Unmarshal(ast, c); err != nil {\n\t\treturn nil, err\n\t}\n\treturn c, nil\n}\n\n", rootType)
+	fmt.Fprintf(&g.buf, "// ToAST converts c into a config AST, using the syntax model in ModelVersion.\n")
+	fmt.Fprintf(&g.buf, "func (c *%s) ToAST() (*parser.VyOSConfigAST, error) {\n", rootType)
+	fmt.Fprintf(&g.buf, "\tconfigModel, err := syntax.GetConfigModel(ModelVersion)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	fmt.Fprintf(&g.buf, "\treturn parser.Marshal(c, configModel)\n}\n")
+
+	if len(options.Subtree) != 0 {
+		if err := g.writeChildTypes(node, options.Subtree); err != nil {
+			return nil, err
+		}
+	}
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Generated code doesn't parse: %v", err)
+	}
+	return src, nil
+}
+
+// writeStruct writes a struct type for the children of `node`,
+// followed by the types for any of its children that need them.
+func (g *generator) writeStruct(name, description string, node *configmodel.VyOSConfigNode, path []string) error {
+	fmt.Fprintf(&g.buf, "// %s holds %s.\n", name, description)
+	fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+
+	fieldNames := map[string]bool{}
+	for _, child := range node.Children {
+		fieldType, err := g.fieldType(child, append(path, child.Name))
+		if err != nil {
+			return err
+		}
+		fieldName := uniqueName(goName(child.Name), fieldNames)
+		fmt.Fprintf(&g.buf, "\t%s %s `vyos:%q`\n", fieldName, fieldType, child.Name)
+	}
+	fmt.Fprintf(&g.buf, "}\n\n")
+
+	for _, child := range node.Children {
+		if err := g.writeChildTypes(child, append(path, child.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeChildTypes writes the struct type for `node`, if it has one.
+func (g *generator) writeChildTypes(node *configmodel.VyOSConfigNode, path []string) error {
+	if node.Type == "leafnode" || len(node.Children) == 0 {
+		return nil
+	}
+	description := fmt.Sprintf("`%s`", strings.Join(path, " "))
+	if node.Type == "tagnode" {
+		description = fmt.Sprintf("each `%s <value>`", strings.Join(path, " "))
+	}
+	return g.writeStruct(g.typeName(path), description, node, path)
+}
+
+// fieldType returns the Go type for a struct field holding `node`.
+// Types for nodes are named after their full config path.
+func (g *generator) fieldType(node *configmodel.VyOSConfigNode, path []string) (string, error) {
+	switch node.Type {
+	case "leafnode":
+		if !node.HasValue {
+			return "bool", nil
+		}
+		if node.Multi {
+			return "[]string", nil
+		}
+		return "*string", nil
+	case "tagnode":
+		if len(node.Children) == 0 {
+			return "map[string]bool", nil
+		}
+		return "map[string]*" + g.typeName(path), nil
+	case "node":
+		if len(node.Children) == 0 {
+			return "bool", nil
+		}
+		return "*" + g.typeName(path), nil
+	}
+	return "", fmt.Errorf("Unknown node type %q for %q", node.Type, strings.Join(path, " "))
+}
+
+// typeName returns the struct type name for the node at `path`.
+// The name is picked the first time a path is seen, and is unique
+// within the file.
+func (g *generator) typeName(path []string) string {
+	key := strings.Join(path, " ")
+	if name, ok := g.typeNames[key]; ok {
+		return name
+	}
+	parts := []string{}
+	for _, word := range path {
+		parts = append(parts, goName(word))
+	}
+	name := uniqueName(strings.Join(parts, ""), g.usedNames)
+	g.typeNames[key] = name
+	return name
+}
+
+// uniqueName returns `name`, or `name` with a numeric suffix if it's
+// already in `used`, and marks the result as used.
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// goName turns a VyOS config word like `hw-id` into an exported Go
+// identifier like `HwId`.
+func goName(word string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range word {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		// Go identifiers can't start with a digit.
+		name = "X" + name
+	}
+	return name
+}
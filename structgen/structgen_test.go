@@ -0,0 +1,117 @@
+package structgen
+
+import (
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/scottlaird/vyos-parser/configmodel"
+	"github.com/scottlaird/vyos-parser/syntax"
+)
+
+func TestGenerate(t *testing.T) {
+	configModel, err := syntax.GetConfigModel("vyos-20250129")
+	if err != nil {
+		t.Fatalf("Failed to load config model: %v", err)
+	}
+
+	src, err := Generate(configModel, Options{Package: "vyosconfig", ModelVersion: "vyos-20250129"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "gen.go", src, 0); err != nil {
+		t.Fatalf("Generated code doesn't parse: %v", err)
+	}
+
+	wants := []string{
+		"package vyosconfig\n",
+		"const ModelVersion = \"vyos-20250129\"\n",
+		"func ConfigFromAST(ast *parser.VyOSConfigAST) (*Config, error) {\n",
+		"func (c *Config) ToAST() (*parser.VyOSConfigAST, error) {\n",
+		"// InterfacesEthernet holds each `interfaces ethernet <value>`.\n",
+	}
+	for _, want := range wants {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Generated code is missing %q", want)
+		}
+	}
+
+	// Fields are aligned by gofmt, so allow for extra spaces.
+	fields := []string{
+		"Interfaces *Interfaces `vyos:\"interfaces\"`",
+		"Ethernet map[string]*InterfacesEthernet `vyos:\"ethernet\"`",
+		"DisableFlowControl bool `vyos:\"disable-flow-control\"`",
+	}
+	for _, field := range fields {
+		re := regexp.MustCompile(`\n\t` + strings.ReplaceAll(regexp.QuoteMeta(field), " ", " +") + `\n`)
+		if !re.Match(src) {
+			t.Errorf("Generated code is missing field %q", field)
+		}
+	}
+}
+
+func TestGenerateSubtree(t *testing.T) {
+	configModel := &configmodel.VyOSConfigNode{
+		Type: "root",
+		Children: []*configmodel.VyOSConfigNode{
+			{Type: "node", Name: "service", Children: []*configmodel.VyOSConfigNode{
+				{Type: "node", Name: "ntp", Children: []*configmodel.VyOSConfigNode{
+					{Type: "leafnode", Name: "listen-address", HasValue: true, Multi: true},
+					{Type: "tagnode", Name: "server", HasValue: true, Children: []*configmodel.VyOSConfigNode{
+						{Type: "leafnode", Name: "prefer"},
+					}},
+					{Type: "leafnode", Name: "vrf", HasValue: true},
+					{Type: "tagnode", Name: "6to4", HasValue: true},
+				}},
+			}},
+		},
+	}
+
+	src, err := Generate(configModel, Options{Package: "ntp", ModelVersion: "vyos-20250129", Subtree: []string{"service", "ntp"}, RootType: "NTP"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	want := `// NTP holds the ` + "`service ntp`" + ` part of the config.
+type NTP struct {
+	Ntp *ServiceNtp ` + "`vyos:\"service/ntp\"`" + `
+}
+`
+	if !strings.Contains(string(src), want) {
+		t.Errorf("Generated code is missing root type:\n%s", src)
+	}
+
+	want = `type ServiceNtp struct {
+	ListenAddress []string                     ` + "`vyos:\"listen-address\"`" + `
+	Server        map[string]*ServiceNtpServer ` + "`vyos:\"server\"`" + `
+	Vrf           *string                      ` + "`vyos:\"vrf\"`" + `
+	X6to4         map[string]bool              ` + "`vyos:\"6to4\"`" + `
+}
+`
+	if !strings.Contains(string(src), want) {
+		t.Errorf("Generated code is missing ServiceNtp:\n%s", src)
+	}
+
+	if _, err := Generate(configModel, Options{Package: "ntp", ModelVersion: "x", Subtree: []string{"service", "ntpd"}}); err == nil {
+		t.Errorf("Expected an error for an unknown subtree")
+	}
+	if _, err := Generate(configModel, Options{Package: "ntp", ModelVersion: "x", Subtree: []string{"service", "ntp", "server", "foo"}}); err == nil {
+		t.Errorf("Expected an error for a subtree past a TagNode")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"hw-id":      "HwId",
+		"ipv6":       "Ipv6",
+		"6rd":        "X6rd",
+		"ip.address": "IpAddress",
+	}
+	for word, want := range tests {
+		if got := goName(word); got != want {
+			t.Errorf("goName(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
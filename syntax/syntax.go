@@ -7,7 +7,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/scottlaird/vyos-parser/configmodel"
 )
@@ -57,3 +61,90 @@ func GetConfigModel(name string) (*configmodel.VyOSConfigNode, error) {
 	}
 	return vc, nil
 }
+
+var (
+	// releaseDateRE matches the build date in rolling release
+	// names like `1.5-rolling-202501060800`.
+	releaseDateRE = regexp.MustCompile(`(20\d{6})\d{4}`)
+
+	// versionDateRE matches the date in embedded model names like
+	// `vyos-20250129`.
+	versionDateRE = regexp.MustCompile(`(\d{8})$`)
+)
+
+// ListVersions returns the names of all of the embedded config
+// models, in sorted order.  Any of these can be passed to
+// GetConfigModel.
+func ListVersions() ([]string, error) {
+	matches, err := fs.Glob(f, "*.json.gz")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for _, match := range matches {
+		versions = append(versions, strings.TrimSuffix(match, ".json.gz"))
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// FindVersionForRelease returns the name of the embedded config model
+// that is closest by date to a VyOS release string, as found in the
+// `// Release version:` footer of config.boot.  Rolling releases
+// include their build date (`1.5-rolling-202501060800`); for
+// releases without a date (`1.4.1`, or an empty string), this returns
+// the default version.
+func FindVersionForRelease(release string) (string, error) {
+	m := releaseDateRE.FindStringSubmatch(release)
+	if m == nil {
+		return GetDefaultVersion()
+	}
+	releaseDate, err := time.Parse("20060102", m[1])
+	if err != nil {
+		return GetDefaultVersion()
+	}
+
+	versions, err := ListVersions()
+	if err != nil {
+		return "", err
+	}
+
+	best := ""
+	var bestDistance time.Duration
+	for _, version := range versions {
+		vm := versionDateRE.FindStringSubmatch(version)
+		if vm == nil {
+			continue
+		}
+		versionDate, err := time.Parse("20060102", vm[1])
+		if err != nil {
+			continue
+		}
+
+		distance := releaseDate.Sub(versionDate).Abs()
+		// Versions are sorted, so on a tie this keeps the
+		// older model.
+		if best == "" || distance < bestDistance {
+			best = version
+			bestDistance = distance
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("No dated config models found for release %q", release)
+	}
+	return best, nil
+}
+
+// GetConfigModelForRelease returns the embedded config model that
+// best matches a VyOS release string, along with the name of the
+// model that was picked.  See FindVersionForRelease for details.
+func GetConfigModelForRelease(release string) (*configmodel.VyOSConfigNode, string, error) {
+	version, err := FindVersionForRelease(release)
+	if err != nil {
+		return nil, "", err
+	}
+	vc, err := GetConfigModel(version)
+	return vc, version, err
+}
@@ -1,6 +1,7 @@
 package syntax
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -26,3 +27,80 @@ func TestGetDefaultConfigModel(t *testing.T) {
 	}
 	// Should probably check that the config is actually sane at some point...
 }
+
+func TestListVersions(t *testing.T) {
+	versions, err := ListVersions()
+	if err != nil {
+		t.Fatalf("Got error from ListVersions(): %v", err)
+	}
+
+	if len(versions) == 0 {
+		t.Fatal("Got no versions from ListVersions()")
+	}
+	if !slices.IsSorted(versions) {
+		t.Errorf("Got unsorted versions=%v", versions)
+	}
+	// Newer models may be added, but these are always here.
+	for _, want := range []string{"vyos-20250126", "vyos-20250127", "vyos-20250129"} {
+		if !slices.Contains(versions, want) {
+			t.Errorf("Version %q not found in %v", want, versions)
+		}
+	}
+
+	defaultVersion, err := GetDefaultVersion()
+	if err != nil {
+		t.Fatalf("Got error from GetDefaultVersion(): %v", err)
+	}
+	found := false
+	for _, version := range versions {
+		if version == defaultVersion {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Default version %q not found in %v", defaultVersion, versions)
+	}
+}
+
+func TestFindVersionForRelease(t *testing.T) {
+	defaultVersion, err := GetDefaultVersion()
+	if err != nil {
+		t.Fatalf("Got error from GetDefaultVersion(): %v", err)
+	}
+
+	tests := []struct {
+		release string
+		want    string
+	}{
+		{"1.5-rolling-202501060800", "vyos-20250126"},
+		{"1.5-rolling-202501270016", "vyos-20250127"},
+		{"1.5-rolling-202501280016", "vyos-20250127"}, // tie goes to the older model
+		{"1.5-rolling-202503010000", "vyos-20250129"},
+		{"1.4.1", defaultVersion},
+		{"", defaultVersion},
+	}
+
+	for _, test := range tests {
+		got, err := FindVersionForRelease(test.release)
+		if err != nil {
+			t.Errorf("Got error from FindVersionForRelease(%q): %v", test.release, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("FindVersionForRelease(%q) = %q, want %q", test.release, got, test.want)
+		}
+	}
+}
+
+func TestGetConfigModelForRelease(t *testing.T) {
+	vc, version, err := GetConfigModelForRelease("1.5-rolling-202501060800")
+	if err != nil {
+		t.Fatalf("Got error from GetConfigModelForRelease(): %v", err)
+	}
+	if vc == nil {
+		t.Fatal("Got nil from GetConfigModelForRelease(), wanted *VyOSConfigNode")
+	}
+	if version != "vyos-20250126" {
+		t.Errorf("Got version %q, want %q", version, "vyos-20250126")
+	}
+}